@@ -0,0 +1,101 @@
+package goleveldb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// restoreBatchSize is how many entries Restore writes per WriteBatch.
+const restoreBatchSize = 1000
+
+// maxBackupFieldLen bounds the length of the keys and values Restore
+// accepts, so that corrupt input doesn't make it allocate huge buffers.
+const maxBackupFieldLen = 1 << 30
+
+// Backup writes all the entries of the database to w, in key order, as a
+// stream that Restore reads back. Each entry is written as the varint
+// length of the key, the key, the varint length of the value and the value.
+//
+// The entries are read from a snapshot: writes made during the backup are
+// not part of it. If ro already has a snapshot, it is used.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) Backup(w io.Writer, ro *ReadOptions) error {
+	ro, release := db.snapshotReadOptions(ro)
+	defer release()
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	bw := bufio.NewWriter(w)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key, value := it.Key(), it.Value()
+		bw.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(key)))])
+		bw.Write(key)
+		bw.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(value)))])
+		if _, err := bw.Write(value); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore puts the entries of a stream written by Backup into the
+// database, in WriteBatches of up to 1000 entries. The existing entries are
+// kept, unless the stream overwrites them.
+//
+// Each batch is applied atomically, but not the restore as a whole: if it
+// fails, the entries of the batches written before remain.
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) Restore(r io.Reader, wo *WriteOptions) error {
+	br := bufio.NewReader(r)
+	w := newBatchWriter(db, wo, restoreBatchSize)
+	defer w.destroy()
+
+	for {
+		key, err := readBackupField(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		value, err := readBackupField(br)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.put(key, value); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+// readBackupField reads a length-prefixed field of a backup stream. It
+// returns io.EOF only if the stream ends before the field.
+func readBackupField(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBackupFieldLen {
+		return nil, errors.New("goleveldb: corrupt backup stream, field too long")
+	}
+	field := make([]byte, n)
+	if _, err := io.ReadFull(br, field); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return field, nil
+}
@@ -2,9 +2,11 @@ package goleveldb
 
 // #cgo LDFLAGS: -lleveldb
 // #include "leveldb/c.h"
+// #include "callbacks.h"
 import "C"
 
 import (
+	"runtime"
 	"unsafe"
 )
 
@@ -28,17 +30,31 @@ import (
 // WriteBatch object.
 type WriteBatch struct {
 	wbatch *C.leveldb_writebatch_t
+
+	// Maintained on the Go side, the C API doesn't expose them.
+	count int // number of updates
+	size  int // bytes taken by the updates in the batch
+
+	pooled int32 // 1 while in a WriteBatchPool
 }
 
 // NewWriteBatch creates a fully allocated WriteBatch.
 func NewWriteBatch() *WriteBatch {
-	return &WriteBatch{C.leveldb_writebatch_create()}
+	w := &WriteBatch{wbatch: C.leveldb_writebatch_create()}
+	runtime.SetFinalizer(w, finalize)
+	trackAlloc(w, "WriteBatch")
+	return w
 }
 
 // Destroy releases the underlying memory of a WriteBatch.
 func (w *WriteBatch) Destroy() {
+	if w.wbatch == nil {
+		return
+	}
+	runtime.SetFinalizer(w, nil)
 	C.leveldb_writebatch_destroy(w.wbatch)
 	w.wbatch = nil
+	trackFree(w)
 }
 
 // Store the mapping "key->value" in the database.
@@ -67,6 +83,8 @@ func (w *WriteBatch) Put(key, value []byte) {
 	C.leveldb_writebatch_put(w.wbatch,
 		keyPtr, C.size_t(keyLen),
 		valuePtr, C.size_t(valueLen))
+	w.count++
+	w.size += 1 + varintLen(keyLen) + keyLen + varintLen(valueLen) + valueLen
 }
 
 // If the database contains a mapping for "key", erase it.
@@ -87,9 +105,134 @@ func (w *WriteBatch) Delete(key []byte) {
 	// Memtable::Add) when called, so we do not need to worry about these
 	// []byte being reclaimed by GC.
 	C.leveldb_writebatch_delete(w.wbatch, keyPtr, C.size_t(keyLen))
+	w.count++
+	w.size += 1 + varintLen(keyLen) + keyLen
 }
 
 // Clear all updates buffered in this batch.
 func (w *WriteBatch) Clear() {
 	C.leveldb_writebatch_clear(w.wbatch)
+	w.count = 0
+	w.size = 0
+}
+
+// Count returns the number of updates buffered in this batch.
+func (w *WriteBatch) Count() int {
+	return w.count
+}
+
+// ApproximateSize returns the number of bytes the updates buffered in this
+// batch take, as encoded by LevelDB. It is 0 for an empty batch, and does
+// not include the few bytes of header of the batch.
+func (w *WriteBatch) ApproximateSize() int {
+	return w.size
+}
+
+// varintLen returns the number of bytes of n encoded as a varint.
+func varintLen(n int) int {
+	l := 1
+	for ; n >= 0x80; n >>= 7 {
+		l++
+	}
+	return l
+}
+
+// Append adds the updates buffered in src to this batch, after the ones it
+// already holds. src is left untouched.
+func (w *WriteBatch) Append(src *WriteBatch) {
+	if src == w {
+		// Don't add to the batch being walked.
+		tmp := NewWriteBatch()
+		defer tmp.Destroy()
+		tmp.Append(src)
+		src = tmp
+	}
+	src.iterate(w.Put, w.Delete)
+}
+
+// batchIterator holds the callbacks of a running WriteBatch.iterate.
+type batchIterator struct {
+	put     func(key, value []byte)
+	deleted func(key []byte)
+}
+
+// Iterate replays the updates buffered in this batch, in the order they
+// were added: put is called for each Put and deleted for each Delete.
+//
+// The slices passed to the callbacks are copies and may be retained. The
+// callbacks must not modify the batch.
+func (w *WriteBatch) Iterate(put func(key, value []byte), deleted func(key []byte)) {
+	w.iterate(func(key, value []byte) {
+		put(append([]byte(nil), key...), append([]byte{}, value...))
+	}, func(key []byte) {
+		deleted(append([]byte(nil), key...))
+	})
+}
+
+// iterate is Iterate without the copies: the slices passed point into the
+// batch and are only valid during the call.
+func (w *WriteBatch) iterate(put func(key, value []byte), deleted func(key []byte)) {
+	h := newHandle(&batchIterator{put, deleted})
+	defer deleteHandle(h)
+	C.goleveldb_writebatch_iterate(w.wbatch, C.uintptr_t(h))
+	runtime.KeepAlive(w)
+}
+
+//export goleveldbWriteBatchPut
+func goleveldbWriteBatchPut(h C.uintptr_t, k *C.char, klen C.size_t, v *C.char, vlen C.size_t) {
+	handleValue(uintptr(h)).(*batchIterator).put(cBytes(k, klen), cBytes(v, vlen))
+}
+
+//export goleveldbWriteBatchDeleted
+func goleveldbWriteBatchDeleted(h C.uintptr_t, k *C.char, klen C.size_t) {
+	handleValue(uintptr(h)).(*batchIterator).deleted(cBytes(k, klen))
+}
+
+// batchWriter applies the updates added to it to a DB, in WriteBatches of
+// at most max updates.
+type batchWriter struct {
+	db  *DB
+	wo  *WriteOptions
+	wb  *WriteBatch
+	n   int
+	max int
+}
+
+func newBatchWriter(db *DB, wo *WriteOptions, max int) *batchWriter {
+	return &batchWriter{db: db, wo: wo, wb: NewWriteBatch(), max: max}
+}
+
+func (w *batchWriter) put(key, value []byte) error {
+	w.wb.Put(key, value)
+	return w.added()
+}
+
+func (w *batchWriter) delete(key []byte) error {
+	w.wb.Delete(key)
+	return w.added()
+}
+
+func (w *batchWriter) added() error {
+	if w.n++; w.n < w.max {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes the pending updates, if any.
+func (w *batchWriter) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	if err := w.db.Write(w.wo, w.wb); err != nil {
+		return err
+	}
+	w.wb.Clear()
+	w.n = 0
+	return nil
+}
+
+// destroy releases the batch, dropping the pending updates.
+func (w *batchWriter) destroy() {
+	w.wb.Destroy()
 }
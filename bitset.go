@@ -0,0 +1,79 @@
+package goleveldb
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+)
+
+// Bitset is a compact, growable set of non-negative integers.
+//
+// The zero value is an empty set ready to use.
+type Bitset struct {
+	words []uint64
+}
+
+// Set adds i to the set. It panics if i is negative.
+func (b *Bitset) Set(i int) {
+	if i < 0 {
+		panic("goleveldb: negative Bitset index")
+	}
+	w := i / 64
+	if w >= len(b.words) {
+		// append grows the capacity geometrically, for ascending Sets.
+		b.words = append(b.words, make([]uint64, w+1-len(b.words))...)
+	}
+	b.words[w] |= 1 << uint(i%64)
+}
+
+// Test reports whether i is in the set.
+func (b *Bitset) Test(i int) bool {
+	w := i / 64
+	if i < 0 || w >= len(b.words) {
+		return false
+	}
+	return b.words[w]&(1<<uint(i%64)) != 0
+}
+
+// Count returns the number of integers in the set.
+func (b *Bitset) Count() int {
+	n := 0
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// BuildKeyBitmap scans the keys starting with prefix and returns a Bitset
+// holding keyToIndex(key) for each of them. Keys for which keyToIndex
+// returns false are skipped. Indexes must not be negative.
+//
+// The key passed to keyToIndex is a copy and may be retained.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) BuildKeyBitmap(ro *ReadOptions, prefix []byte,
+	keyToIndex func(key []byte) (int, bool)) (*Bitset, error) {
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	bitmap := new(Bitset)
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		i, ok := keyToIndex(key)
+		if !ok {
+			continue
+		}
+		if i < 0 {
+			return nil, errors.New("goleveldb: negative bitmap index")
+		}
+		bitmap.Set(i)
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return bitmap, nil
+}
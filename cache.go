@@ -2,8 +2,23 @@ package goleveldb
 
 // #cgo LDFLAGS: -lleveldb
 // #include "leveldb/c.h"
+//
+// // leveldb_cache_get_usage is only in some versions of the C API. The weak
+// // reference resolves to NULL when the linked library lacks it.
+// extern size_t leveldb_cache_get_usage(leveldb_cache_t* cache) __attribute__((weak));
+//
+// static size_t goleveldb_cache_get_usage(leveldb_cache_t* cache) {
+//   if (leveldb_cache_get_usage == NULL) {
+//     return 0;
+//   }
+//   return leveldb_cache_get_usage(cache);
+// }
 import "C"
 
+import (
+	"runtime"
+)
+
 // A Cache is an interface that maps keys to values.  It has internal
 // synchronization and may be safely accessed concurrently from
 // multiple threads.  It may automatically evict entries to make room
@@ -22,20 +37,51 @@ import "C"
 //  NOTE: if the process is shutting down,
 //  this may not be necessary and could be avoided to shorten shutdown time.
 type Cache struct {
-	cache *C.leveldb_cache_t
+	cache    *C.leveldb_cache_t
+	capacity uint64
 }
 
 // NewLRUCache create a new cache with a fixed size capacity.
 // This implementation of Cache uses a least-recently-used eviction policy.
 //
+// The cache is split internally in 16 shards, each holding a sixteenth of the
+// capacity, to reduce lock contention; the C API doesn't allow choosing
+// their number.
+//
 // To prevent memory leaks, Destroy should be called on the Cache when the
 // program no longer needs it.
 func NewLRUCache(capacity int) *Cache {
-	return &Cache{C.leveldb_cache_create_lru(C.size_t(capacity))}
+	c := &Cache{
+		cache:    C.leveldb_cache_create_lru(C.size_t(capacity)),
+		capacity: uint64(capacity),
+	}
+	runtime.SetFinalizer(c, finalize)
+	trackAlloc(c, "Cache")
+	return c
 }
 
 // Destroy deallocates the underlying memory of the Cache object.
 func (c *Cache) Destroy() {
+	if c.cache == nil {
+		return
+	}
+	runtime.SetFinalizer(c, nil)
 	C.leveldb_cache_destroy(c.cache)
 	c.cache = nil
+	trackFree(c)
+}
+
+// Capacity returns the capacity the Cache was created with, in bytes.
+func (c *Cache) Capacity() uint64 {
+	return c.capacity
+}
+
+// Usage returns the total charge of the entries in the Cache, in bytes.
+//
+// It uses leveldb_cache_get_usage, which the C API of LevelDB doesn't have
+// in most versions: Usage returns 0 when the linked library lacks it.
+func (c *Cache) Usage() uint64 {
+	usage := uint64(C.goleveldb_cache_get_usage(c.cache))
+	runtime.KeepAlive(c)
+	return usage
 }
@@ -0,0 +1,71 @@
+#include "callbacks.h"
+#include "_cgo_export.h"
+
+// The Go side releases its resources itself.
+static void goleveldb_destructor(void* state) {}
+
+static void goleveldb_writebatch_put(void* state,
+	const char* k, size_t klen,
+	const char* v, size_t vlen) {
+
+	goleveldbWriteBatchPut((uintptr_t)state, (char*)k, klen, (char*)v, vlen);
+}
+
+static void goleveldb_writebatch_deleted(void* state,
+	const char* k, size_t klen) {
+
+	goleveldbWriteBatchDeleted((uintptr_t)state, (char*)k, klen);
+}
+
+void goleveldb_writebatch_iterate(leveldb_writebatch_t* b, uintptr_t h) {
+	leveldb_writebatch_iterate(b, (void*)h,
+		goleveldb_writebatch_put,
+		goleveldb_writebatch_deleted);
+}
+
+static int goleveldb_comparator_compare(void* state,
+	const char* a, size_t alen,
+	const char* b, size_t blen) {
+
+	return goleveldbComparatorCompare((uintptr_t)state, (char*)a, alen, (char*)b, blen);
+}
+
+static const char* goleveldb_comparator_name(void* state) {
+	return goleveldbComparatorName((uintptr_t)state);
+}
+
+leveldb_comparator_t* goleveldb_comparator_create(uintptr_t h) {
+	return leveldb_comparator_create((void*)h,
+		goleveldb_destructor,
+		goleveldb_comparator_compare,
+		goleveldb_comparator_name);
+}
+
+static char* goleveldb_filterpolicy_create_filter(void* state,
+	const char* const* key_array, const size_t* key_length_array,
+	int num_keys,
+	size_t* filter_length) {
+
+	return goleveldbFilterPolicyCreate((uintptr_t)state,
+		(char**)key_array, (size_t*)key_length_array, num_keys, filter_length);
+}
+
+static unsigned char goleveldb_filterpolicy_key_may_match(void* state,
+	const char* key, size_t length,
+	const char* filter, size_t filter_length) {
+
+	return goleveldbFilterPolicyKeyMayMatch((uintptr_t)state,
+		(char*)key, length, (char*)filter, filter_length);
+}
+
+static const char* goleveldb_filterpolicy_name(void* state) {
+	return goleveldbFilterPolicyName((uintptr_t)state);
+}
+
+leveldb_filterpolicy_t* goleveldb_filterpolicy_create(uintptr_t h) {
+	return leveldb_filterpolicy_create((void*)h,
+		goleveldb_destructor,
+		goleveldb_filterpolicy_create_filter,
+		goleveldb_filterpolicy_key_may_match,
+		goleveldb_filterpolicy_name);
+}
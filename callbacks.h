@@ -0,0 +1,19 @@
+// C trampolines for the LevelDB callbacks implemented in Go.
+//
+// LevelDB passes an opaque state pointer to its callbacks. The trampolines
+// use it to carry a handle (see handle.go) identifying the Go value to call
+// back, and forward the calls to the exported Go functions.
+
+#ifndef GOLEVELDB_CALLBACKS_H_
+#define GOLEVELDB_CALLBACKS_H_
+
+#include <stdint.h>
+#include "leveldb/c.h"
+
+void goleveldb_writebatch_iterate(leveldb_writebatch_t* b, uintptr_t h);
+
+leveldb_comparator_t* goleveldb_comparator_create(uintptr_t h);
+
+leveldb_filterpolicy_t* goleveldb_filterpolicy_create(uintptr_t h);
+
+#endif  // GOLEVELDB_CALLBACKS_H_
@@ -0,0 +1,64 @@
+package goleveldb
+
+import (
+	"bytes"
+)
+
+// CompareAndSwap sets the value of key to newValue if its current value is
+// oldValue, and reports whether it did. A nil oldValue stands for a missing
+// key, and a nil newValue deletes the key; use empty non-nil slices for
+// empty values.
+//
+// LevelDB has no native compare-and-swap: CompareAndSwap reads the value
+// and then writes the new one. Concurrent CompareAndSwap calls on the same
+// DB are serialized, but other writes, from Put, Delete, Write or another
+// process, may happen between the read and the write and be overwritten.
+// Guard them with a lock shared with the CompareAndSwap callers if that
+// matters.
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) CompareAndSwap(wo *WriteOptions, key, oldValue, newValue []byte) (bool, error) {
+	db.casMu.Lock()
+	defer db.casMu.Unlock()
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	swapped, err := db.CompareAndSwapBatch(nil, wb, key, oldValue, newValue)
+	if !swapped || err != nil {
+		return false, err
+	}
+	if err := db.Write(wo, wb); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompareAndSwapBatch is the building block of CompareAndSwap for callers
+// doing their own locking: if the value of key read with ro is oldValue, it
+// adds the update setting it to newValue to wb and returns true. The swap
+// happens when wb is written.
+//
+// Reading with a snapshot lets several comparisons see the same state of the
+// database, to apply their updates together in wb.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) CompareAndSwapBatch(ro *ReadOptions, wb *WriteBatch, key, oldValue, newValue []byte) (bool, error) {
+	current, err := db.Get(ro, key)
+	switch {
+	case err == ErrNotFound:
+		if oldValue != nil {
+			return false, nil
+		}
+	case err != nil:
+		return false, err
+	case oldValue == nil || !bytes.Equal(current, oldValue):
+		return false, nil
+	}
+
+	if newValue == nil {
+		wb.Delete(key)
+	} else {
+		wb.Put(key, newValue)
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package goleveldb
+
+// ChunkedBatch buffers updates and writes them to a DB in WriteBatches of a
+// bounded number of updates, to apply a large set of updates without
+// holding it all in memory nor blocking the log for the time of a single
+// huge write.
+//
+// Each chunk is applied atomically, but not the updates as a whole: readers
+// may see the first chunks written before the last ones are.
+//
+// A ChunkedBatch is not safe for concurrent use. To prevent memory leaks,
+// call Destroy when the program no longer needs it.
+type ChunkedBatch struct {
+	w *batchWriter
+}
+
+// NewChunkedBatch returns a ChunkedBatch writing to the DB with wo, in
+// chunks of maxOpsPerChunk updates. A maxOpsPerChunk below 1 is taken as 1.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) NewChunkedBatch(wo *WriteOptions, maxOpsPerChunk int) *ChunkedBatch {
+	if maxOpsPerChunk < 1 {
+		maxOpsPerChunk = 1
+	}
+	return &ChunkedBatch{newBatchWriter(db, wo, maxOpsPerChunk)}
+}
+
+// Put adds the mapping "key->value", writing the pending chunk if it is
+// full. The key and value byte slices may be reused.
+func (b *ChunkedBatch) Put(key, value []byte) error {
+	return b.w.put(key, value)
+}
+
+// Delete adds the deletion of key, writing the pending chunk if it is full.
+// The key byte slice may be reused.
+func (b *ChunkedBatch) Delete(key []byte) error {
+	return b.w.delete(key)
+}
+
+// Flush writes the pending updates, if any. It must be called once all the
+// updates are added, the last chunk is otherwise lost on Destroy.
+func (b *ChunkedBatch) Flush() error {
+	return b.w.flush()
+}
+
+// Destroy releases the ChunkedBatch, dropping the updates not flushed yet.
+func (b *ChunkedBatch) Destroy() {
+	b.w.destroy()
+}
+
+// WriteChunked applies the updates of wb in WriteBatches of at most
+// maxOpsPerChunk updates, written one after the other; see ChunkedBatch. A
+// batch that fits in a single chunk is written as is. A maxOpsPerChunk
+// below 1 is taken as 1.
+//
+// On error, the updates of the chunks already written stay applied and the
+// following ones are not.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) WriteChunked(wo *WriteOptions, wb *WriteBatch, maxOpsPerChunk int) error {
+	if wb.Count() <= maxOpsPerChunk {
+		return db.Write(wo, wb)
+	}
+
+	b := db.NewChunkedBatch(wo, maxOpsPerChunk)
+	defer b.Destroy()
+	// The walk over wb can't be stopped: skip the updates after an error.
+	var err error
+	wb.iterate(func(key, value []byte) {
+		if err == nil {
+			err = b.Put(key, value)
+		}
+	}, func(key []byte) {
+		if err == nil {
+			err = b.Delete(key)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return b.Flush()
+}
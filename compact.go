@@ -0,0 +1,137 @@
+package goleveldb
+
+import (
+	"context"
+	"time"
+)
+
+// level0StallTrigger mirrors LevelDB's kL0_SlowdownWritesTrigger: past that
+// many level-0 files writes get slowed down, as compactions don't keep up.
+const level0StallTrigger = 8
+
+// recompactInterval is how often RecompactOnError checks level 0.
+const recompactInterval = 10 * time.Second
+
+// RecompactOnError enables or disables watching for stalled compactions.
+//
+// LevelDB gives up on a background compaction that hits an error (e.g. a
+// transient IO error) until something triggers a new one, and the C API has
+// no knob to make it retry. When enabled, the number of level-0 files is
+// checked periodically, and if they pile up past the point where LevelDB
+// starts slowing writes down, a full CompactRange is run to nudge the
+// compactions along.
+//
+// Close disables it.
+func (db *DB) RecompactOnError(enable bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if enable == (db.recompactStop != nil) {
+		return
+	}
+	if !enable {
+		close(db.recompactStop)
+		<-db.recompactDone
+		db.recompactStop, db.recompactDone = nil, nil
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	db.recompactStop, db.recompactDone = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(recompactInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				db.recompactIfStalled(level0StallTrigger)
+			}
+		}
+	}()
+}
+
+// recompactIfStalled compacts the whole database if there are more than
+// threshold files at level 0, and reports whether it did.
+func (db *DB) recompactIfStalled(threshold int) bool {
+	n, err := db.NumFilesAtLevel(0)
+	if err != nil || n <= threshold {
+		return false
+	}
+	db.CompactRange(nil, nil)
+	return true
+}
+
+// CompactRangeContext is CompactRange, returning ctx.Err() if ctx is done
+// before the compaction finishes.
+//
+// A compaction can't be interrupted: when ctx is done, control returns to
+// the caller while the compaction goes on in the background. Close waits for
+// it to finish.
+func (db *DB) CompactRangeContext(ctx context.Context, begin, end []byte) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	// The caller may reuse them once we return.
+	begin = append([]byte(nil), begin...)
+	end = append([]byte(nil), end...)
+
+	return db.runContext(ctx, func() error {
+		db.CompactRange(begin, end)
+		return nil
+	})
+}
+
+// CompactAllAsync starts compacting the whole database, as CompactRange(nil,
+// nil) does, in the background, and returns a channel that receives nil once
+// the compaction is done, or ErrClosed if the DB is closed.
+//
+// Only one such compaction runs at a time: when one is already running, no
+// other is started, and the channel receives nil when the running one is
+// done. Close waits for the compaction to finish.
+func (db *DB) CompactAllAsync() <-chan error {
+	done := make(chan error, 1)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.db == nil {
+		done <- ErrClosed
+		return done
+	}
+	db.compactWaiters = append(db.compactWaiters, done)
+	if len(db.compactWaiters) > 1 {
+		return done
+	}
+
+	db.background.Add(1)
+	go func() {
+		defer db.background.Done()
+		db.CompactRange(nil, nil)
+
+		db.mu.Lock()
+		waiters := db.compactWaiters
+		db.compactWaiters = nil
+		db.mu.Unlock()
+		for _, c := range waiters {
+			c <- nil
+		}
+	}()
+	return done
+}
+
+// Flush writes the data held in memory, in the memtable, to table files, so
+// that the files of the database hold all the writes made before the call,
+// e.g. before copying them for a backup. Until then, the latest writes are
+// only in the log file.
+//
+// The C API has no lighter way to do it: Flush compacts the whole database
+// with CompactRange(nil, nil), which can take a while on a large database.
+func (db *DB) Flush() error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	db.CompactRange(nil, nil)
+	return nil
+}
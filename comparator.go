@@ -0,0 +1,92 @@
+package goleveldb
+
+// #cgo LDFLAGS: -lleveldb
+// #include <stdlib.h>
+// #include "leveldb/c.h"
+// #include "callbacks.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// A Comparator provides a total order across the keys of a database,
+// defined by a Go function.
+//
+// To prevent memory leaks, a Comparator must have Destroy called on it when
+// it is no longer needed by the program, after any database using it has
+// been closed.
+type Comparator struct {
+	cmp     *C.leveldb_comparator_t
+	name    *C.char
+	compare func(a, b []byte) int
+	handle  uintptr
+}
+
+// NewComparator returns a Comparator ordering keys with compare, which must
+// return a negative number, zero or a positive number when a is less than,
+// equal to or greater than b.
+//
+// The name is stored in the database when it is created, and LevelDB refuses
+// to open it later with a comparator of another name. The name should be
+// changed whenever the ordering changes in an incompatible way.
+//
+// The slices passed to compare point to memory owned by LevelDB, compare
+// must not modify or retain them.
+func NewComparator(name string, compare func(a, b []byte) int) *Comparator {
+	c := &Comparator{
+		name:    C.CString(name),
+		compare: compare,
+	}
+	c.handle = newHandle(c)
+	c.cmp = C.goleveldb_comparator_create(C.uintptr_t(c.handle))
+	trackAlloc(c, "Comparator")
+	return c
+}
+
+// ComparatorImpl is an ordering of keys given by a type, see
+// NewComparatorFromImpl.
+type ComparatorImpl interface {
+	// Compare returns a negative number, zero or a positive number when a
+	// is less than, equal to or greater than b.
+	Compare(a, b []byte) int
+
+	// Name identifies the ordering, see NewComparator.
+	Name() string
+}
+
+// NewComparatorFromImpl returns a Comparator ordering keys with impl. It is
+// NewComparator(impl.Name(), impl.Compare).
+func NewComparatorFromImpl(impl ComparatorImpl) *Comparator {
+	return NewComparator(impl.Name(), impl.Compare)
+}
+
+// Destroy deallocates the Comparator, freeing its underlying C struct.
+func (c *Comparator) Destroy() {
+	C.leveldb_comparator_destroy(c.cmp)
+	c.cmp = nil
+	deleteHandle(c.handle)
+	C.free(unsafe.Pointer(c.name))
+	c.name = nil
+	trackFree(c)
+}
+
+//export goleveldbComparatorCompare
+func goleveldbComparatorCompare(h C.uintptr_t, a *C.char, alen C.size_t, b *C.char, blen C.size_t) C.int {
+	c := handleValue(uintptr(h)).(*Comparator)
+	return C.int(c.compare(cBytes(a, alen), cBytes(b, blen)))
+}
+
+//export goleveldbComparatorName
+func goleveldbComparatorName(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*Comparator).name
+}
+
+// comparatorName returns the name of c, or that of LevelDB's default
+// comparator if c is nil.
+func comparatorName(c *Comparator) string {
+	if c == nil {
+		return "leveldb.BytewiseComparator"
+	}
+	return C.GoString(c.name)
+}
@@ -0,0 +1,132 @@
+package goleveldb
+
+import (
+	"context"
+)
+
+// GetContext is Get, returning ctx.Err() if ctx is done before the read
+// finishes.
+//
+// Cancellation doesn't abort the read: control returns to the caller while
+// it goes on in the background, and its result is discarded. It works on
+// copies of key and ro, and the snapshot of ro is only freed once the read
+// is over, even if it is released before. Close waits for the reads left in
+// the background.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) GetContext(ctx context.Context, ro *ReadOptions, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if ro != nil && ro.snap != nil && !db.holdSnapshot(ro.snap) {
+		// Get fails without reading: the snapshot is released or the DB
+		// closed.
+		return db.Get(ro, key)
+	}
+	key = append([]byte(nil), key...)
+	if ro != nil {
+		ro = ro.Clone()
+	}
+
+	type result struct {
+		value []byte
+		err   error
+	}
+	resc := make(chan result, 1)
+	db.background.Add(1)
+	go func() {
+		defer db.background.Done()
+		if ro != nil {
+			defer ro.Destroy()
+			if ro.snap != nil {
+				defer db.unholdSnapshot(ro.snap)
+			}
+		}
+		value, err := db.Get(ro, key)
+		resc <- result{value, err}
+	}()
+
+	select {
+	case res := <-resc:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PutContext is Put, returning ctx.Err() if ctx is done before the write
+// finishes.
+//
+// Cancellation doesn't abort the write, which may still be applied: control
+// returns to the caller while it goes on in the background. It works on
+// copies of key, value and wo. Close waits for the writes left in the
+// background.
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) PutContext(ctx context.Context, wo *WriteOptions, key, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key = append([]byte(nil), key...)
+	value = append([]byte(nil), value...)
+	if wo != nil {
+		wo = wo.Clone()
+	}
+
+	return db.runContext(ctx, func() error {
+		if wo != nil {
+			defer wo.Destroy()
+		}
+		return db.Put(wo, key, value)
+	})
+}
+
+// WriteContext is Write, returning ctx.Err() if ctx is done before the
+// write finishes.
+//
+// Cancellation doesn't abort the write, which may still be applied: control
+// returns to the caller while it goes on in the background. It works on
+// copies of wb and wo. Close waits for the writes left in the background.
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) WriteContext(ctx context.Context, wo *WriteOptions, wb *WriteBatch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	batch := NewWriteBatch()
+	batch.Append(wb)
+	if wo != nil {
+		wo = wo.Clone()
+	}
+
+	return db.runContext(ctx, func() error {
+		defer batch.Destroy()
+		if wo != nil {
+			defer wo.Destroy()
+		}
+		return db.Write(wo, batch)
+	})
+}
+
+// runContext runs op in a goroutine and returns its error, or ctx.Err() if
+// ctx is done first. op is not started if ctx is already done, otherwise it
+// always runs to completion, with Close waiting for it.
+func (db *DB) runContext(ctx context.Context, op func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 1)
+	db.background.Add(1)
+	go func() {
+		defer db.background.Done()
+		errc <- op()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
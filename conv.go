@@ -2,6 +2,10 @@ package goleveldb
 
 import "C"
 
+import (
+	"unsafe"
+)
+
 func bool2uchar(b bool) C.uchar {
 	uc := C.uchar(0)
 	if b {
@@ -16,3 +20,12 @@ func uchar2bool(uc C.uchar) bool {
 	}
 	return true
 }
+
+// cBytes returns a slice over n bytes of C memory at p, without copying
+// them. The slice is only valid as long as the C memory is.
+func cBytes(p *C.char, n C.size_t) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), int(n))
+}
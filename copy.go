@@ -0,0 +1,60 @@
+package goleveldb
+
+import (
+	"errors"
+)
+
+// copyBatchSize is how many entries CopyTo writes per WriteBatch.
+const copyBatchSize = 1000
+
+// CopyTo creates a new database at destPath holding a copy of all the
+// entries of the DB.
+//
+// The copy is made from a snapshot while the DB stays fully usable: writes
+// made during the copy are not part of it. Unlike copying the files of a
+// database, the result is compacted and can be opened with different
+// Options, provided they use the same comparator: CopyTo fails if destOpt
+// sets a comparator of another name than the one of the DB, or none while
+// the DB has one.
+//
+// Set the Options default if destOpt == nil, except that the database is
+// created, must not exist yet, and uses the comparator of the DB.
+func (db *DB) CopyTo(destPath string, destOpt *Options) error {
+	if destOpt == nil {
+		destOpt = NewOptions()
+		defer destOpt.Destroy()
+		destOpt.SetCreateIfMissing(true)
+		destOpt.SetErrorIfExists(true)
+		destOpt.SetComparator(db.comparator)
+	}
+	if comparatorName(destOpt.cmp) != comparatorName(db.comparator) {
+		return errors.New("goleveldb: CopyTo with another comparator than the DB's")
+	}
+	dest, err := Open(destPath, destOpt)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetFillCache(false)
+	ro.SetSnapshot(snap)
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	w := newBatchWriter(dest, nil, copyBatchSize)
+	defer w.destroy()
+
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if err := w.put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return w.flush()
+}
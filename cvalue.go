@@ -0,0 +1,67 @@
+package goleveldb
+
+// #cgo LDFLAGS: -lleveldb
+// #include "leveldb/c.h"
+import "C"
+
+import (
+	"unsafe"
+)
+
+// A CValue is a value read by DB.GetRaw, left in the C memory LevelDB
+// returned it in.
+//
+// Free must be called once the value is no longer needed: unlike the other
+// resources of the package, a CValue has no finalizer, since the slices
+// returned by Bytes don't keep it reachable.
+type CValue struct {
+	value *C.char
+	n     C.size_t
+}
+
+// GetRaw is like Get, but returns the value without copying it to Go
+// memory, which saves a copy and an allocation per read on hot paths.
+//
+// The value must be released with Free. Using the slices returned by its
+// Bytes method after that is a use after free, that can crash the program
+// or silently read other data.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) GetRaw(ro *ReadOptions, key []byte) (*CValue, error) {
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Get", key, timeNow())
+	}
+
+	cvalue, vallen, err := db.get(ro, key)
+	if err != nil {
+		return nil, err
+	}
+	v := &CValue{value: cvalue, n: vallen}
+	trackAlloc(v, "CValue")
+	return v, nil
+}
+
+// Bytes returns the value, as a slice of the C memory holding it. The slice
+// is only valid until Free is called, and must not be modified.
+func (v *CValue) Bytes() []byte {
+	if v.value == nil {
+		return nil
+	}
+	return cBytes(v.value, v.n)
+}
+
+// Len returns the length of the value.
+func (v *CValue) Len() int {
+	return int(v.n)
+}
+
+// Free releases the C memory of the value. Calling it again does nothing.
+func (v *CValue) Free() {
+	if v.value == nil {
+		return
+	}
+	C.leveldb_free(unsafe.Pointer(v.value))
+	v.value = nil
+	v.n = 0
+	trackFree(v)
+}
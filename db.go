@@ -25,7 +25,13 @@ void goleveldb_leveldb_approximate_sizes(
 import "C"
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -38,6 +44,12 @@ type Range struct {
 	Limit []byte // Not included in the range
 }
 
+// KV is a key/value pair.
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
 // Snapshot provides a consistent view of read operations in a DB. It is set
 // on to a ReadOptions and passed in. It is only created by DB.NewSnapshot.
 //
@@ -45,7 +57,34 @@ type Range struct {
 // returned must be released with DB.ReleaseSnapshot method on the DB that
 // created it.
 type Snapshot struct {
-	snap *C.leveldb_snapshot_t
+	snap     *C.leveldb_snapshot_t
+	seq      uint64
+	released int32 // set atomically by ReleaseSnapshot
+	counted  bool  // taken before Close, counted by SnapshotCount
+	refs     int   // background reads using it, guarded by the DB's mu
+}
+
+// ID identifies the snapshot among the snapshots of its DB, it is its
+// SequenceNumber. Two *Snapshot with the same ID and DB are the same
+// snapshot.
+func (s *Snapshot) ID() uint64 {
+	return s.seq
+}
+
+// Released reports whether the snapshot has been released. The reads
+// through a released snapshot fail with ErrSnapshotReleased.
+func (s *Snapshot) Released() bool {
+	return atomic.LoadInt32(&s.released) != 0
+}
+
+// SequenceNumber returns the number of the snapshot among the snapshots
+// taken on its DB: the snapshots of a DB are numbered 1, 2, 3... in the
+// order they are taken.
+//
+// The C API doesn't expose LevelDB's own sequence numbers, so the numbering
+// starts over each time the database is opened.
+func (s *Snapshot) SequenceNumber() uint64 {
+	return s.seq
 }
 
 // A DB is a persistent ordered map from keys to values.
@@ -53,12 +92,59 @@ type Snapshot struct {
 // any external synchronization.
 //
 // To avoid memory and file descriptor leaks, call Close when the process no
-// longer needs the handle. Calls to any DB method made after Close will
-// panic.
+// longer needs the handle. The DB methods called after Close return
+// ErrClosed.
 type DB struct {
 	db          *C.leveldb_t
+	name        string
 	defaultROpt *ReadOptions
 	defaultWOpt *WriteOptions
+
+	// WriteOptions of PutSync, DeleteSync and WriteSync, created on first use.
+	syncWOpt     *WriteOptions
+	syncWOptOnce sync.Once
+
+	// cmp orders keys the same way the database does: the function of the
+	// Comparator it was opened with, or LevelDB's default bytewise ordering.
+	cmp func(a, b []byte) int
+	// bytewise is true when the DB uses the default ordering.
+	bytewise bool
+	// comparator is the Comparator of the Options, nil for the default.
+	comparator *Comparator
+
+	// The Cache, FilterPolicy, Env and info log of the Options, used by the
+	// C database: keeping them reachable keeps them from being finalized.
+	cache        *Cache
+	filterPolicy *FilterPolicy
+	env          *Env
+	infoLog      *infoLog
+
+	logFollower *logFollower // copying the LOG file, see SetInfoLogFile
+
+	slowOpThreshold time.Duration
+	slowOpLogger    Logger
+
+	snapshots   int64  // live snapshots, updated atomically
+	snapshotSeq uint64 // last Snapshot.SequenceNumber, updated atomically
+
+	// background counts the calls still running after the caller of a
+	// ...Context method gave up on them. Close waits for them.
+	background sync.WaitGroup
+
+	casMu sync.Mutex // serializes CompareAndSwap calls
+
+	mu             sync.Mutex
+	closers        []Destroyer
+	liveSnapshots  map[*Snapshot]struct{} // released by Close if still live
+	recompactStop  chan struct{}
+	recompactDone  chan struct{}
+	compactWaiters []chan error // callers of the running CompactAllAsync
+}
+
+// Destroyer is implemented by the resources that are released with a
+// Destroy method, such as Cache and FilterPolicy.
+type Destroyer interface {
+	Destroy()
 }
 
 // Open is shorthand for OpenEx(dbname, opt, nil, nil).
@@ -66,6 +152,22 @@ func Open(dbname string, opt *Options) (*DB, error) {
 	return OpenEx(dbname, opt, nil, nil)
 }
 
+// OpenWithFallback tries to open the databases at paths in order, and
+// returns the first one that opens along with its path. If none does, the
+// error of the last attempt is returned.
+//
+// Set the Options opt default if nil
+func OpenWithFallback(paths []string, opt *Options) (*DB, string, error) {
+	err := errors.New("goleveldb: no database path given")
+	for _, path := range paths {
+		var db *DB
+		if db, err = Open(path, opt); err == nil {
+			return db, path, nil
+		}
+	}
+	return nil, "", err
+}
+
 // OpenEx open the database with the specified "dbname".
 // Returned a pointer to a heap-allocated database and nil error.
 // Returned a nil pointer and an error.
@@ -91,7 +193,7 @@ func OpenEx(dbname string, opt *Options,
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return nil, errors.New(gs)
+		return nil, newLevelDBError(gs)
 	}
 
 	if defaultROpt == nil {
@@ -100,10 +202,34 @@ func OpenEx(dbname string, opt *Options,
 	if defaultWOpt == nil {
 		defaultWOpt = NewWriteOptions()
 	}
-	return &DB{
-		db:          leveldb,
-		defaultROpt: defaultROpt,
-		defaultWOpt: defaultWOpt}, nil
+	cmp := bytes.Compare
+	if opt.cmp != nil {
+		cmp = opt.cmp.compare
+	}
+	slowOpLogger := opt.slowOpLogger
+	if slowOpLogger == nil {
+		slowOpLogger = stdLogger{}
+	}
+	db := &DB{
+		db:              leveldb,
+		name:            dbname,
+		defaultROpt:     defaultROpt,
+		defaultWOpt:     defaultWOpt,
+		cmp:             cmp,
+		bytewise:        opt.cmp == nil,
+		comparator:      opt.cmp,
+		slowOpThreshold: opt.slowOpThreshold,
+		slowOpLogger:    slowOpLogger,
+		cache:           opt.cache,
+		filterPolicy:    opt.filterPolicy,
+		env:             opt.env,
+		infoLog:         opt.infoLog}
+	trackAlloc(db, "DB")
+	if err := db.followInfoLog(opt); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 // Destroy the contents of the specified database.
@@ -124,7 +250,7 @@ func DestroyDatabase(dbname string, o *Options) error {
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
@@ -149,24 +275,79 @@ func RepairDatabase(dbname string, o *Options) error {
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
 
 // Close the database, rendering it unusable for I/O, by deallocating
-// the underlying handle.
+// the underlying handle. Resources registered with AddCloser are destroyed
+// afterwards. The snapshots not released yet are released before.
 //
-// Any attempts to use the DB after Close is called will panic.
-func (db *DB) Close() {
+// Closing a closed DB does nothing. Close always returns nil, it returns an
+// error to satisfy io.Closer.
+//
+// The methods of a closed DB return ErrClosed, or do nothing if they return
+// no error. Close must not be called while other goroutines use the DB, and
+// the Iterators of the DB must be closed before it.
+func (db *DB) Close() error {
+	if db.db == nil {
+		return nil
+	}
+	db.RecompactOnError(false)
+	db.background.Wait()
+
+	// LevelDB doesn't free the snapshots left when the database is closed.
+	db.mu.Lock()
+	for snap := range db.liveSnapshots {
+		db.freeSnapshot(snap)
+	}
+	db.liveSnapshots = nil
+	db.mu.Unlock()
+
 	C.leveldb_close(db.db)
 	db.db = nil
+	if db.logFollower != nil {
+		db.logFollower.close()
+		db.logFollower = nil
+	}
+	db.cache, db.filterPolicy, db.env, db.infoLog = nil, nil, nil, nil
 
+	// The destroyed default options are kept: the methods called after
+	// Close may still read their settings.
 	db.defaultROpt.Destroy()
-	db.defaultROpt = nil
-
 	db.defaultWOpt.Destroy()
-	db.defaultWOpt = nil
+	if db.syncWOpt != nil {
+		db.syncWOpt.Destroy()
+	}
+	trackFree(db)
+
+	db.mu.Lock()
+	closers := db.closers
+	db.closers = nil
+	db.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Destroy()
+	}
+	return nil
+}
+
+// AddCloser registers c to be destroyed when the DB is closed.
+//
+// This is meant for the resources attached to the Options the DB was opened
+// with (a Cache, a FilterPolicy, ...), which must outlive the DB. Close
+// destroys them after the underlying database has been closed, in the
+// reverse order of registration.
+func (db *DB) AddCloser(c Destroyer) {
+	db.mu.Lock()
+	db.closers = append(db.closers, c)
+	db.mu.Unlock()
+}
+
+// Path returns the name the database was opened with, which is the path of
+// its directory.
+func (db *DB) Path() string {
+	return db.name
 }
 
 func (db *DB) MajorVersion() int {
@@ -197,6 +378,13 @@ var (
 //
 // Set the WriteOptions default if wo == nil
 func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Put", key, timeNow())
+	}
+
 	var keyPtr, valuePtr *C.char
 	var keyLen, valueLen = len(key), len(value)
 
@@ -226,11 +414,12 @@ func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
 		keyPtr, C.size_t(keyLen),
 		valuePtr, C.size_t(valueLen),
 		&errStr)
+	runtime.KeepAlive(wo)
 
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
@@ -248,6 +437,71 @@ func (db *DB) Put(wo *WriteOptions, key, value []byte) error {
 //
 // Set the ReadOptions default if ro == nil
 func (db *DB) Get(ro *ReadOptions, key []byte) (value []byte, err error) {
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Get", key, timeNow())
+	}
+
+	cvalue, vallen, err := db.get(ro, key)
+	if err != nil {
+		return nil, err
+	}
+	value = C.GoBytes(unsafe.Pointer(cvalue), C.int(vallen))
+	C.leveldb_free(unsafe.Pointer(cvalue))
+	return
+}
+
+// GetInto is like Get, but copies the value into dst instead of allocating
+// a new slice, and returns its length n.
+//
+// If the value doesn't fit in dst, nothing is copied and io.ErrShortBuffer
+// is returned along with the length of the value, the size of the buffer
+// needed to read it. If the key does not exist in the database, ErrNotFound
+// is returned.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) GetInto(ro *ReadOptions, key, dst []byte) (n int, err error) {
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Get", key, timeNow())
+	}
+
+	cvalue, vallen, err := db.get(ro, key)
+	if err != nil {
+		return 0, err
+	}
+	defer C.leveldb_free(unsafe.Pointer(cvalue))
+	if int(vallen) > len(dst) {
+		return int(vallen), io.ErrShortBuffer
+	}
+	return copy(dst, cBytes(cvalue, vallen)), nil
+}
+
+// Has reports whether the key exists in the database. The value is read,
+// but not copied to Go memory, which makes Has cheaper than Get to probe
+// keys with large values.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) Has(ro *ReadOptions, key []byte) (bool, error) {
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Get", key, timeNow())
+	}
+
+	cvalue, _, err := db.get(ro, key)
+	switch err {
+	case nil:
+		C.leveldb_free(unsafe.Pointer(cvalue))
+		return true, nil
+	case ErrNotFound:
+		return false, nil
+	}
+	return false, err
+}
+
+// get reads the value of key with leveldb_get. The value must be freed with
+// leveldb_free.
+func (db *DB) get(ro *ReadOptions, key []byte) (cvalue *C.char, vallen C.size_t, err error) {
+	if db.db == nil {
+		return nil, 0, ErrClosed
+	}
 	var keyPtr *C.char
 	var keyLen = len(key)
 
@@ -260,32 +514,58 @@ func (db *DB) Get(ro *ReadOptions, key []byte) (value []byte, err error) {
 	if ro == nil {
 		ro = db.defaultROpt
 	}
+	if ro.snap != nil && ro.snap.Released() {
+		return nil, 0, ErrSnapshotReleased
+	}
 
 	var errStr *C.char
-	var vallen C.size_t
 	// leveldb_put, _get, and _delete call memcpy() (by way of Memtable::Add)
 	// when called, so we do not need to worry about these []byte being
 	// reclaimed by GC.
-	cvalue := C.leveldb_get(
+	cvalue = C.leveldb_get(
 		db.db,
 		ro.opt,
 		keyPtr, C.size_t(keyLen),
 		&vallen,
 		&errStr)
+	runtime.KeepAlive(ro)
 
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return nil, errors.New(gs)
+		return nil, 0, newLevelDBError(gs)
 	}
 
 	if cvalue == nil {
-		return nil, ErrNotFound
+		return nil, 0, ErrNotFound
 	}
+	return cvalue, vallen, nil
+}
 
-	value = C.GoBytes(unsafe.Pointer(cvalue), C.int(vallen))
-	C.leveldb_free(unsafe.Pointer(cvalue))
-	return
+// NextKeyAfter returns the first key strictly greater than key in the
+// database, along with its value. If there is none, ErrNotFound is returned.
+//
+// This is the building block of readers polling for entries appended after
+// the last one they saw.
+//
+// The key byte slice may be reused safely. next and value are copies.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NextKeyAfter(ro *ReadOptions, key []byte) (next, value []byte, err error) {
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	it.Seek(key)
+	if it.Valid() && db.cmp(it.Key(), key) == 0 {
+		it.Next()
+	}
+	if !it.Valid() {
+		if err = it.Error(); err == nil {
+			err = ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return it.Key(), it.Value(), nil
 }
 
 // Remove the database entry (if any) for "key".  Returns nil on
@@ -298,6 +578,13 @@ func (db *DB) Get(ro *ReadOptions, key []byte) (value []byte, err error) {
 //
 // Set the WriteOptions default if wo == nil
 func (db *DB) Delete(wo *WriteOptions, key []byte) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Delete", key, timeNow())
+	}
+
 	var keyPtr *C.char
 	var keyLen = len(key)
 
@@ -320,11 +607,12 @@ func (db *DB) Delete(wo *WriteOptions, key []byte) error {
 		wo.opt,
 		keyPtr, C.size_t(keyLen),
 		&errStr)
+	runtime.KeepAlive(wo)
 
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
@@ -335,20 +623,171 @@ func (db *DB) Delete(wo *WriteOptions, key []byte) error {
 //
 // Set the WriteOptions default if wo == nil
 func (db *DB) Write(wo *WriteOptions, wb *WriteBatch) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	if db.slowOpThreshold > 0 {
+		defer db.logSlowOp("Write", nil, timeNow())
+	}
+
 	if wo == nil {
 		wo = db.defaultWOpt
 	}
 
 	var errStr *C.char
 	C.leveldb_write(db.db, wo.opt, wb.wbatch, &errStr)
+	runtime.KeepAlive(wo)
+	runtime.KeepAlive(wb)
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
 
+// PutSync is Put with WriteOptions.SetSync(true): the write is flushed to
+// disk before PutSync returns.
+func (db *DB) PutSync(key, value []byte) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	return db.Put(db.syncWriteOptions(), key, value)
+}
+
+// DeleteSync is Delete with WriteOptions.SetSync(true): the deletion is
+// flushed to disk before DeleteSync returns.
+func (db *DB) DeleteSync(key []byte) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	return db.Delete(db.syncWriteOptions(), key)
+}
+
+// WriteSync is Write with WriteOptions.SetSync(true): the updates are
+// flushed to disk before WriteSync returns.
+func (db *DB) WriteSync(wb *WriteBatch) error {
+	if db.db == nil {
+		return ErrClosed
+	}
+	return db.Write(db.syncWriteOptions(), wb)
+}
+
+// syncWriteOptions returns the WriteOptions of the ...Sync methods.
+func (db *DB) syncWriteOptions() *WriteOptions {
+	db.syncWOptOnce.Do(func() {
+		db.syncWOpt = NewWriteOptions()
+		db.syncWOpt.SetSync(true)
+	})
+	return db.syncWOpt
+}
+
+// WriteAll applies the updates of all the batches to the database
+// atomically, as if they had been appended to a single WriteBatch in order:
+// when several batches touch the same key, the last one wins.
+//
+// The batches are not modified and still belong to the caller.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) WriteAll(wo *WriteOptions, batches ...*WriteBatch) error {
+	if len(batches) == 1 {
+		return db.Write(wo, batches[0])
+	}
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for _, b := range batches {
+		wb.Append(b)
+	}
+	return db.Write(wo, wb)
+}
+
+// PutMulti stores all the key/value pairs of kvs atomically, in a single
+// WriteBatch. The keys of a map are all different, so the order in which
+// they are added to the batch doesn't matter.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) PutMulti(wo *WriteOptions, kvs map[string][]byte) error {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for key, value := range kvs {
+		wb.Put([]byte(key), value)
+	}
+	return db.Write(wo, wb)
+}
+
+// PutMany puts all the entries atomically, in a single WriteBatch. Unlike
+// with PutMulti, the entries are added to the batch in order, so when a key
+// is repeated, its last entry wins.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) PutMany(wo *WriteOptions, entries []KV) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for _, e := range entries {
+		wb.Put(e.Key, e.Value)
+	}
+	return db.Write(wo, wb)
+}
+
+// DeleteMulti deletes all the keys atomically, in a single WriteBatch.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) DeleteMulti(wo *WriteOptions, keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for _, key := range keys {
+		wb.Delete(key)
+	}
+	return db.Write(wo, wb)
+}
+
+// DeleteRange deletes all the keys in [r.Start, r.Limit) atomically. A nil
+// Start or Limit leaves the range unbounded on that side, and keys are
+// compared with the DB's comparator.
+//
+// The C API has no range deletion: the keys of the range are read from a
+// snapshot and deleted one by one, in a single WriteBatch. Keys put in the
+// range while DeleteRange runs may survive it.
+//  NOTE: consider WriteOptions.SetSync(true).
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) DeleteRange(wo *WriteOptions, r Range) error {
+	ro, release := db.snapshotReadOptions(nil)
+	defer release()
+	it := db.NewRangeIterator(ro, r)
+	defer it.Close()
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for ; it.Valid(); it.Next() {
+		wb.Delete(it.Key())
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if wb.Count() == 0 {
+		return nil
+	}
+	return db.Write(wo, wb)
+}
+
 // NewIterator returns an Iterator over the the database that uses the
 // ReadOptions given.
 //
@@ -362,12 +801,100 @@ func (db *DB) Write(wo *WriteOptions, wb *WriteBatch) error {
 //
 // Set the ReadOptions default if ro == nil
 func (db *DB) NewIterator(ro *ReadOptions) *Iterator {
+	if db.db == nil {
+		// never valid, Error returns ErrClosed
+		it := &Iterator{db: db, err: ErrClosed}
+		trackAlloc(it, "Iterator")
+		return it
+	}
 	if ro == nil {
 		ro = db.defaultROpt
 	}
+	if ro.snap != nil && ro.snap.Released() {
+		// never valid, Error returns ErrSnapshotReleased
+		it := &Iterator{db: db, snap: ro.snap, err: ErrSnapshotReleased}
+		trackAlloc(it, "Iterator")
+		return it
+	}
 
-	it := C.leveldb_create_iterator(db.db, ro.opt)
-	return &Iterator{iter: it}
+	it := &Iterator{
+		iter: C.leveldb_create_iterator(db.db, ro.opt),
+		db:   db,
+		snap: ro.snap,
+	}
+	runtime.KeepAlive(ro)
+	trackAlloc(it, "Iterator")
+	return it
+}
+
+// NewRangeIterator returns an Iterator over the keys in [r.Start, r.Limit),
+// positioned at the first of them. A nil Start or Limit leaves the range
+// unbounded on that side.
+//
+// The iterator doesn't go out of the range: Valid returns false once it
+// moves past either end, and SeekToFirst and SeekToLast move to the ends of
+// the range. Keys are compared with the DB's comparator.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewRangeIterator(ro *ReadOptions, r Range) *Iterator {
+	it := db.NewIterator(ro)
+	if r.Start != nil {
+		it.start = append([]byte(nil), r.Start...)
+	}
+	if r.Limit != nil {
+		it.limit = append([]byte(nil), r.Limit...)
+	}
+	it.SeekToFirst()
+	return it
+}
+
+// NewReverseIterator returns an Iterator walking the database backwards,
+// positioned at its last key, so that the usual loop goes through the keys
+// in descending order:
+//
+// 	it := db.NewReverseIterator(readOpts)
+// 	defer it.Close()
+// 	for ; it.Valid(); it.Next() {
+// 		useKeyAndValue(it.Key(), it.Value())
+// 	}
+//
+// The directions of the Iterator are inverted: Next moves to the previous
+// key and Prev to the next one, SeekToFirst moves to the last key and
+// SeekToLast to the first one, Seek moves to the key or the previous one and
+// SeekForPrev to the key or the next one.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewReverseIterator(ro *ReadOptions) *Iterator {
+	it := db.NewIterator(ro)
+	it.reverse = true
+	it.SeekToFirst()
+	return it
+}
+
+// CountRange returns the number of keys in [r.Start, r.Limit). A nil Start
+// or Limit leaves the range unbounded on that side.
+//
+// The keys are counted by iterating over them, without copying them.
+//
+// Set the ReadOptions default if ro == nil, except that the blocks read
+// don't fill the cache.
+func (db *DB) CountRange(ro *ReadOptions, r Range) (int, error) {
+	if ro == nil {
+		ro = NewReadOptions()
+		defer ro.Destroy()
+		ro.SetFillCache(false)
+	}
+	it := db.NewRangeIterator(ro, r)
+	defer it.Close()
+
+	n := 0
+	for ; it.Valid(); it.Next() {
+		n++
+	}
+	if err := it.GetError(); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 // GetSnapshot creates a new snapshot of the database.
@@ -381,14 +908,131 @@ func (db *DB) NewIterator(ro *ReadOptions) *Iterator {
 //
 // See the LevelDB documentation for details.
 func (db *DB) GetSnapshot() *Snapshot {
-	snap := C.leveldb_create_snapshot(db.db)
-	return &Snapshot{snap: snap}
+	if db.db == nil {
+		// reads with it fail with ErrClosed
+		return &Snapshot{seq: atomic.AddUint64(&db.snapshotSeq, 1)}
+	}
+	atomic.AddInt64(&db.snapshots, 1)
+	snap := &Snapshot{
+		snap:    C.leveldb_create_snapshot(db.db),
+		seq:     atomic.AddUint64(&db.snapshotSeq, 1),
+		counted: true,
+	}
+	db.mu.Lock()
+	if db.liveSnapshots == nil {
+		db.liveSnapshots = make(map[*Snapshot]struct{})
+	}
+	db.liveSnapshots[snap] = struct{}{}
+	db.mu.Unlock()
+	trackAlloc(snap, "Snapshot")
+	return snap
 }
 
 // ReleaseSnapshot removes the snapshot from the database's list of snapshots,
-// and deallocates it.
+// and deallocates it. Releasing a released snapshot does nothing.
+//
+// Close releases the snapshots still live: releasing them afterwards only
+// updates the count of SnapshotCount.
 func (db *DB) ReleaseSnapshot(snap *Snapshot) {
+	if !atomic.CompareAndSwapInt32(&snap.released, 0, 1) || !snap.counted {
+		return // released already, or taken after Close
+	}
+	db.mu.Lock()
+	if _, live := db.liveSnapshots[snap]; live && snap.refs == 0 {
+		db.freeSnapshot(snap)
+	}
+	db.mu.Unlock()
+	atomic.AddInt64(&db.snapshots, -1)
+	trackFree(snap)
+}
+
+// holdSnapshot keeps snap from being freed by ReleaseSnapshot until
+// unholdSnapshot is called, for a read going on in the background. It
+// returns false if snap is released, or was freed by Close.
+func (db *DB) holdSnapshot(snap *Snapshot) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, live := db.liveSnapshots[snap]; !live || snap.Released() {
+		return false
+	}
+	snap.refs++
+	return true
+}
+
+// unholdSnapshot ends a holdSnapshot, freeing snap if it was released in
+// the meantime.
+func (db *DB) unholdSnapshot(snap *Snapshot) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if snap.refs--; snap.refs == 0 && snap.Released() {
+		db.freeSnapshot(snap)
+	}
+}
+
+// freeSnapshot frees the C snapshot of snap. db.mu must be held.
+func (db *DB) freeSnapshot(snap *Snapshot) {
+	if _, live := db.liveSnapshots[snap]; !live {
+		return
+	}
 	C.leveldb_release_snapshot(db.db, snap.snap)
+	snap.snap = nil
+	delete(db.liveSnapshots, snap)
+}
+
+// SnapshotCount returns the number of snapshots taken with GetSnapshot and
+// not released yet.
+func (db *DB) SnapshotCount() int {
+	return int(atomic.LoadInt64(&db.snapshots))
+}
+
+// View calls fn with a snapshot of the database, released when fn returns
+// or panics, and returns the error of fn. fn must not use the snapshot
+// after returning.
+func (db *DB) View(fn func(snap *Snapshot) error) error {
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	return fn(snap)
+}
+
+// GetAt is like Get with default ReadOptions reading from snap.
+func (db *DB) GetAt(snap *Snapshot, key []byte) ([]byte, error) {
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+	return db.Get(ro, key)
+}
+
+// NewIteratorAt is like NewIterator with default ReadOptions reading from
+// snap. The snapshot must not be released before the Iterator is closed.
+func (db *DB) NewIteratorAt(snap *Snapshot) *Iterator {
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+	// leveldb_create_iterator doesn't keep the options
+	return db.NewIterator(ro)
+}
+
+// snapshotReadOptions returns ReadOptions whose reads all see the same
+// snapshot of the database. If ro already carries a snapshot it is returned
+// as is, otherwise a snapshot is taken and bound to a copy of ro.
+//
+// The returned func releases anything allocated here and must be called
+// once the reads are done.
+func (db *DB) snapshotReadOptions(ro *ReadOptions) (*ReadOptions, func()) {
+	if ro == nil {
+		ro = db.defaultROpt
+	}
+	if ro.snap != nil {
+		return ro, func() {}
+	}
+
+	snap := db.GetSnapshot()
+	sro := ro.Clone()
+	sro.SetSnapshot(snap)
+	return sro, func() {
+		sro.Destroy()
+		db.ReleaseSnapshot(snap)
+	}
 }
 
 // GetProperty returns the value of a database property.
@@ -405,6 +1049,9 @@ func (db *DB) ReleaseSnapshot(snap *Snapshot) {
 //  "leveldb.sstables" - returns a multi-line string that describes all
 //     of the sstables that make up the db contents.
 func (db *DB) GetProperty(property string) (value string) {
+	if db.db == nil {
+		return ""
+	}
 	cname := C.CString(property)
 	cvalue := C.leveldb_property_value(db.db, cname)
 	C.free(unsafe.Pointer(cname))
@@ -427,8 +1074,8 @@ func (db *DB) GetProperty(property string) (value string) {
 // The results may not include the sizes of recently written data.
 func (db *DB) GetApproximateSizes(ranges []Range) (sizes []uint64) {
 	rangeNum := len(ranges)
-	if rangeNum == 0 {
-		return make([]uint64, 0)
+	if rangeNum == 0 || db.db == nil {
+		return make([]uint64, rangeNum)
 	}
 
 	num_ranges := C.int(rangeNum)
@@ -468,6 +1115,14 @@ func (db *DB) GetApproximateSizes(ranges []Range) (sizes []uint64) {
 	return
 }
 
+// ApproximateSize returns the approximate file system space used by the
+// keys in [r.Start, r.Limit), see GetApproximateSizes. It returns 0 once the
+// DB is closed.
+func (db *DB) ApproximateSize(r Range) uint64 {
+	// GetApproximateSizes returns as many sizes as ranges, even when closed.
+	return db.GetApproximateSizes([]Range{r})[0]
+}
+
 // Compact the underlying storage for the key range [begin, end].
 // In particular, deleted and overwritten versions are discarded,
 // and the data is rearranged to reduce the cost of operations
@@ -480,6 +1135,9 @@ func (db *DB) GetApproximateSizes(ranges []Range) (sizes []uint64) {
 //
 //  db.CompactRange(nil, nil);
 func (db *DB) CompactRange(begin, end []byte) {
+	if db.db == nil {
+		return
+	}
 	var beginPtr, endPtr *C.char
 	var beginLen, endLen = len(begin), len(end)
 	if beginLen != 0 {
@@ -0,0 +1,44 @@
+package goleveldb
+
+// #cgo LDFLAGS: -lleveldb
+// #include "leveldb/c.h"
+import "C"
+
+import (
+	"runtime"
+)
+
+// An Env is the interface LevelDB uses to access the operating system:
+// files, background threads, clock, etc.
+//
+// The C API of LevelDB only gives access to the default Env, backed by the
+// file system; in particular the in-memory Env of LevelDB can't be used from
+// Go. Tests that don't want to deal with database directories can use the
+// leveldbtest package.
+//
+// To prevent memory leaks, Destroy must be called on an Env when the program
+// no longer needs it.
+type Env struct {
+	env *C.leveldb_env_t
+}
+
+// NewDefaultEnv returns the default Env of LevelDB. All the default Envs
+// share the same state, such as the background thread.
+func NewDefaultEnv() *Env {
+	e := &Env{env: C.leveldb_create_default_env()}
+	runtime.SetFinalizer(e, finalize)
+	trackAlloc(e, "Env")
+	return e
+}
+
+// Destroy deallocates the Env. The default Env itself lives as long as the
+// program.
+func (e *Env) Destroy() {
+	if e.env == nil {
+		return
+	}
+	runtime.SetFinalizer(e, nil)
+	C.leveldb_env_destroy(e.env)
+	e.env = nil
+	trackFree(e)
+}
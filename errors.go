@@ -0,0 +1,101 @@
+package goleveldb
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors matching the kinds of failure statuses returned by
+// LevelDB, to test with errors.Is.
+var (
+	ErrCorruption      = errors.New("goleveldb: corruption")
+	ErrIOError         = errors.New("goleveldb: IO error")
+	ErrInvalidArgument = errors.New("goleveldb: invalid argument")
+)
+
+// ErrClosed is returned by the methods of a DB that has been closed, and by
+// the iterators created after it was.
+var ErrClosed = errors.New("goleveldb: DB closed")
+
+// ErrSnapshotReleased is returned by the reads through a snapshot that has
+// been released, and by the iterators created with one.
+var ErrSnapshotReleased = errors.New("goleveldb: snapshot released")
+
+// ErrorKind is the category of a LevelDBError.
+type ErrorKind int
+
+const (
+	KindUnknown ErrorKind = iota
+	KindNotFound
+	KindCorruption
+	KindIOError
+	KindInvalidArgument
+)
+
+// statusKinds maps the prefixes of LevelDB status strings to their kind.
+var statusKinds = []struct {
+	prefix string
+	kind   ErrorKind
+}{
+	{"NotFound: ", KindNotFound},
+	{"Corruption: ", KindCorruption},
+	{"IO error: ", KindIOError},
+	{"Invalid argument: ", KindInvalidArgument},
+}
+
+// kindErrors maps the kinds to the sentinel errors LevelDBError unwraps to.
+var kindErrors = map[ErrorKind]error{
+	KindNotFound:        ErrNotFound,
+	KindCorruption:      ErrCorruption,
+	KindIOError:         ErrIOError,
+	KindInvalidArgument: ErrInvalidArgument,
+}
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindCorruption:
+		return "corruption"
+	case KindIOError:
+		return "IO error"
+	case KindInvalidArgument:
+		return "invalid argument"
+	}
+	return "unknown"
+}
+
+// LevelDBError is an error returned by LevelDB. Msg is the status string of
+// LevelDB, and Kind its category, parsed from the prefix of the status.
+//
+// A LevelDBError unwraps to the sentinel error of its kind: ErrNotFound,
+// ErrCorruption, ErrIOError or ErrInvalidArgument.
+//
+// 	if errors.Is(err, goleveldb.ErrCorruption) {
+// 		...
+// 	}
+type LevelDBError struct {
+	Msg  string
+	Kind ErrorKind
+}
+
+// newLevelDBError returns a *LevelDBError for the LevelDB status string msg.
+func newLevelDBError(msg string) error {
+	e := &LevelDBError{Msg: msg}
+	for _, s := range statusKinds {
+		if strings.HasPrefix(msg, s.prefix) {
+			e.Kind = s.kind
+			break
+		}
+	}
+	return e
+}
+
+func (e *LevelDBError) Error() string {
+	return e.Msg
+}
+
+// Unwrap returns the sentinel error of the kind of e, nil for KindUnknown.
+func (e *LevelDBError) Unwrap() error {
+	return kindErrors[e.Kind]
+}
@@ -1,9 +1,16 @@
 package goleveldb
 
 // #cgo LDFLAGS: -lleveldb
+// #include <stdlib.h>
 // #include "leveldb/c.h"
+// #include "callbacks.h"
 import "C"
 
+import (
+	"runtime"
+	"unsafe"
+)
+
 // A database can be configured with a custom FilterPolicy object.
 // This object is responsible for creating a small filter from a set
 // of keys.  These filters are stored in leveldb and are consulted
@@ -19,6 +26,18 @@ import "C"
 // it is no longer needed by the program.
 type FilterPolicy struct {
 	fp *C.leveldb_filterpolicy_t
+
+	// Set for policies implemented in Go.
+	impl   *goFilterPolicy
+	handle uintptr
+}
+
+// goFilterPolicy is what the callbacks of a policy implemented in Go get.
+// It is registered instead of the FilterPolicy, which would otherwise stay
+// reachable from the registry and never be finalized.
+type goFilterPolicy struct {
+	impl FilterPolicyImpl
+	name *C.char
 }
 
 // Return a new filter policy that uses a bloom filter with approximately
@@ -36,11 +55,116 @@ type FilterPolicy struct {
 // FilterPolicy (like NewBloomFilterPolicy) that does not ignore
 // trailing spaces in keys.
 func NewBloomFilterPolicy(bitsPerKey int) *FilterPolicy {
-	return &FilterPolicy{C.leveldb_filterpolicy_create_bloom(C.int(bitsPerKey))}
+	fp := &FilterPolicy{fp: C.leveldb_filterpolicy_create_bloom(C.int(bitsPerKey))}
+	runtime.SetFinalizer(fp, finalize)
+	trackAlloc(fp, "FilterPolicy")
+	return fp
+}
+
+// FilterPolicyImpl is a filter policy implemented in Go, see
+// NewFilterPolicy.
+type FilterPolicyImpl interface {
+	// CreateFilter returns a filter summarizing keys, which are sorted
+	// according to the database's comparator.
+	CreateFilter(keys [][]byte) []byte
+
+	// KeyMayMatch must return true if key was in the keys the filter was
+	// created from. It may return true or false otherwise, but should
+	// return false with a high probability.
+	KeyMayMatch(key, filter []byte) bool
+
+	// Name identifies the encoding of the filters. If it changes, the
+	// filters stored in the database are ignored.
+	Name() string
 }
 
+// NewFilterPolicy returns a FilterPolicy implemented by impl.
+//
+// The slices passed to impl point to memory owned by LevelDB, impl must not
+// modify or retain them.
+//
+// Callers must delete the result after any database that is using the
+// result has been closed.
+func NewFilterPolicy(impl FilterPolicyImpl) *FilterPolicy {
+	fp := &FilterPolicy{
+		impl: &goFilterPolicy{
+			impl: impl,
+			name: C.CString(impl.Name()),
+		},
+	}
+	fp.handle = newHandle(fp.impl)
+	fp.fp = C.goleveldb_filterpolicy_create(C.uintptr_t(fp.handle))
+	runtime.SetFinalizer(fp, finalize)
+	trackAlloc(fp, "FilterPolicy")
+	return fp
+}
+
+// NewFilterPolicyFunc returns a FilterPolicy named name, implemented by the
+// functions given, see FilterPolicyImpl.
+//
+// Callers must delete the result after any database that is using the
+// result has been closed.
+func NewFilterPolicyFunc(name string, createFilter func(keys [][]byte) []byte,
+	keyMayMatch func(key, filter []byte) bool) *FilterPolicy {
+	return NewFilterPolicy(&filterPolicyFuncs{name, createFilter, keyMayMatch})
+}
+
+// filterPolicyFuncs is the FilterPolicyImpl of NewFilterPolicyFunc.
+type filterPolicyFuncs struct {
+	name         string
+	createFilter func(keys [][]byte) []byte
+	keyMayMatch  func(key, filter []byte) bool
+}
+
+func (f *filterPolicyFuncs) CreateFilter(keys [][]byte) []byte   { return f.createFilter(keys) }
+func (f *filterPolicyFuncs) KeyMayMatch(key, filter []byte) bool { return f.keyMayMatch(key, filter) }
+func (f *filterPolicyFuncs) Name() string                        { return f.name }
+
 // Destroy releases the underlying memory of a FilterPolicy.
 func (fp *FilterPolicy) Destroy() {
+	if fp.fp == nil {
+		return
+	}
+	runtime.SetFinalizer(fp, nil)
 	C.leveldb_filterpolicy_destroy(fp.fp)
 	fp.fp = nil
+	if fp.impl != nil {
+		deleteHandle(fp.handle)
+		C.free(unsafe.Pointer(fp.impl.name))
+		fp.impl = nil
+	}
+	trackFree(fp)
+}
+
+//export goleveldbFilterPolicyCreate
+func goleveldbFilterPolicyCreate(h C.uintptr_t, keyArray **C.char, keyLengthArray *C.size_t,
+	numKeys C.int, filterLength *C.size_t) *C.char {
+
+	n := int(numKeys)
+	keys := make([][]byte, n)
+	if n > 0 {
+		ptrs := unsafe.Slice(keyArray, n)
+		lens := unsafe.Slice(keyLengthArray, n)
+		for i := range keys {
+			keys[i] = cBytes(ptrs[i], lens[i])
+		}
+	}
+	filter := handleValue(uintptr(h)).(*goFilterPolicy).impl.CreateFilter(keys)
+
+	// LevelDB frees the filter with free().
+	*filterLength = C.size_t(len(filter))
+	return (*C.char)(C.CBytes(filter))
+}
+
+//export goleveldbFilterPolicyKeyMayMatch
+func goleveldbFilterPolicyKeyMayMatch(h C.uintptr_t, key *C.char, length C.size_t,
+	filter *C.char, filterLength C.size_t) C.uchar {
+
+	fp := handleValue(uintptr(h)).(*goFilterPolicy)
+	return bool2uchar(fp.impl.KeyMayMatch(cBytes(key, length), cBytes(filter, filterLength)))
+}
+
+//export goleveldbFilterPolicyName
+func goleveldbFilterPolicyName(h C.uintptr_t) *C.char {
+	return handleValue(uintptr(h)).(*goFilterPolicy).name
 }
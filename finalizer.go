@@ -0,0 +1,19 @@
+package goleveldb
+
+import (
+	"sync/atomic"
+)
+
+// finalized counts the objects freed by finalize, for tests.
+var finalized int64
+
+// finalize is the finalizer of the objects holding C memory that can be
+// garbage collected without having been destroyed: it frees their C memory
+// in place of the forgotten Destroy. Destroy cancels it with
+// runtime.SetFinalizer(x, nil).
+//
+// Finalizers are not guaranteed to run, Destroy should still be called.
+func finalize(d Destroyer) {
+	atomic.AddInt64(&finalized, 1)
+	d.Destroy()
+}
@@ -0,0 +1,41 @@
+package goleveldb
+
+import (
+	"sync"
+)
+
+// C code calling back into Go can't hold Go pointers, it is given a handle
+// instead: an integer standing for a Go value in this registry.
+var handles struct {
+	sync.RWMutex
+	values map[uintptr]interface{}
+	next   uintptr
+}
+
+// newHandle registers v and returns its handle. v stays alive until the
+// handle is deleted.
+func newHandle(v interface{}) uintptr {
+	handles.Lock()
+	defer handles.Unlock()
+
+	if handles.values == nil {
+		handles.values = make(map[uintptr]interface{})
+	}
+	handles.next++
+	handles.values[handles.next] = v
+	return handles.next
+}
+
+// handleValue returns the value registered under handle h.
+func handleValue(h uintptr) interface{} {
+	handles.RLock()
+	defer handles.RUnlock()
+	return handles.values[h]
+}
+
+// deleteHandle unregisters handle h.
+func deleteHandle(h uintptr) {
+	handles.Lock()
+	delete(handles.values, h)
+	handles.Unlock()
+}
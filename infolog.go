@@ -0,0 +1,132 @@
+package goleveldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SetInfoLogFile makes the DBs opened with o append the lines of their info
+// log to the file at path, created if missing, e.g. to gather the logs of
+// several databases in one place.
+//
+// The C API can't redirect the info log: LevelDB still writes it to the
+// LOG file of the database directory, and the DB follows that file, copying
+// the lines added within a fraction of a second; the lines logged up to
+// Close are copied before it returns. Open fails if path can't be opened.
+//
+// SetInfoLogFile replaces the setting of SetInfoLog. An empty path removes
+// the setting.
+//
+//  Default: ""
+func (o *Options) SetInfoLogFile(path string) {
+	o.clearInfoLog()
+	o.infoLogFile = path
+}
+
+// infoLogPollInterval is how often a DB following its LOG file reads the
+// lines added to it.
+const infoLogPollInterval = 100 * time.Millisecond
+
+// logFollower passes the lines added to a LOG file to out.
+type logFollower struct {
+	log     *os.File
+	out     func(line []byte)
+	closeFn func() error // closes the destination of out, if any
+	partial []byte       // start of a line not fully written yet
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// followInfoLog starts following the LOG file of the DB, if opt asks for
+// it with SetInfoLogFile or SetInfoLog.
+func (db *DB) followInfoLog(opt *Options) error {
+	f := &logFollower{stop: make(chan struct{}), done: make(chan struct{})}
+	switch {
+	case opt.infoLogFile != "":
+		dest, err := os.OpenFile(opt.infoLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		f.out = func(line []byte) { dest.Write(append(line, '\n')) }
+		f.closeFn = dest.Close
+	case opt.infoLogFunc != nil:
+		fn := opt.infoLogFunc
+		f.out = func(line []byte) { fn(string(stripLogHeader(line))) }
+	default:
+		return nil
+	}
+
+	log, err := os.Open(filepath.Join(db.name, "LOG"))
+	if err != nil {
+		if f.closeFn != nil {
+			f.closeFn()
+		}
+		return err
+	}
+	f.log = log
+	db.logFollower = f
+	go f.run()
+	return nil
+}
+
+func (f *logFollower) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(infoLogPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			f.read()
+			f.log.Close()
+			if f.closeFn != nil {
+				f.closeFn()
+			}
+			return
+		case <-ticker.C:
+			f.read()
+		}
+	}
+}
+
+// read passes out the complete lines added to the LOG file since the last
+// call.
+func (f *logFollower) read() {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := f.log.Read(buf)
+		data := append(f.partial, buf[:n]...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			f.out(data[:i:i])
+			data = data[i+1:]
+		}
+		f.partial = append([]byte(nil), data...)
+		if err != nil || n == 0 {
+			return // io.EOF: the end written so far
+		}
+	}
+}
+
+// close stops following the LOG file, after passing the lines left.
+func (f *logFollower) close() {
+	close(f.stop)
+	<-f.done
+}
+
+// stripLogHeader removes the timestamp and thread id LevelDB writes at the
+// start of the lines of its LOG file:
+//
+//	2006/01/02-15:04:05.000000 7f5e8c5f9700 Recovering log #3
+func stripLogHeader(line []byte) []byte {
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		if j := bytes.IndexByte(line[i+1:], ' '); j >= 0 {
+			return line[i+1+j+1:]
+		}
+	}
+	return line
+}
@@ -0,0 +1,82 @@
+//go:build goleveldb_cxx
+
+// A leveldb::Logger forwarding the lines LevelDB logs to a Go function.
+//
+// The C API can set a logger on the options but not create one, so this
+// part is written against the C++ API of LevelDB, and relies on the layout
+// of leveldb_logger_t, private to db/c.cc: it is only built with the
+// goleveldb_cxx tag.
+
+#include <stdarg.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+#include "leveldb/env.h"
+
+#include "leveldb/c.h"
+// The preamble of infolog_cxx.go, declaring the functions below, is C.
+extern "C" {
+#include "_cgo_export.h"
+}
+
+// As defined by the C API of LevelDB, in db/c.cc.
+struct leveldb_logger_t { leveldb::Logger* rep; };
+
+namespace {
+
+class GoLogger : public leveldb::Logger {
+ public:
+  explicit GoLogger(uintptr_t h) : h_(h) { }
+
+  virtual void Logv(const char* format, va_list ap) {
+    // Try a stack buffer first, then a buffer of the formatted size.
+    char buf[512];
+    char* msg = buf;
+    va_list copy;
+    va_copy(copy, ap);
+    int n = vsnprintf(buf, sizeof(buf), format, copy);
+    va_end(copy);
+    if (n < 0) {
+      return;
+    }
+    if (n >= static_cast<int>(sizeof(buf))) {
+      msg = static_cast<char*>(malloc(n + 1));
+      if (msg == NULL) {
+        return;
+      }
+      va_copy(copy, ap);
+      vsnprintf(msg, n + 1, format, copy);
+      va_end(copy);
+    }
+
+    // The lines are logged with or without a final newline.
+    if (n > 0 && msg[n-1] == '\n') {
+      n--;
+    }
+    goleveldbInfoLog(h_, msg, n);
+
+    if (msg != buf) {
+      free(msg);
+    }
+  }
+
+ private:
+  uintptr_t h_;
+};
+
+}  // namespace
+
+extern "C" {
+
+leveldb_logger_t* goleveldb_logger_create(uintptr_t h) {
+  leveldb_logger_t* l = new leveldb_logger_t;
+  l->rep = new GoLogger(h);
+  return l;
+}
+
+void goleveldb_logger_destroy(leveldb_logger_t* l) {
+  delete l->rep;
+  delete l;
+}
+
+}  // extern "C"
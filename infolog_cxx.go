@@ -0,0 +1,75 @@
+//go:build goleveldb_cxx
+
+package goleveldb
+
+// #cgo LDFLAGS: -lleveldb
+// #include <stdint.h>
+// #include "leveldb/c.h"
+//
+// // Implemented in C++ in infolog_cxx.cc: the C API has no way to create a
+// // logger.
+// leveldb_logger_t* goleveldb_logger_create(uintptr_t h);
+// void goleveldb_logger_destroy(leveldb_logger_t* l);
+import "C"
+
+import (
+	"runtime"
+)
+
+// Any internal progress/error information generated by the db will
+// be passed to fn, one line at a time, if it is non-nil, or written to a
+// file stored in the same directory as the DB contents if fn is nil.
+//
+// Built with the goleveldb_cxx tag, fn is installed as the logger of
+// LevelDB, and no LOG file is written. LevelDB logs from its background
+// thread as well as from the goroutines using the DB: fn must be safe for
+// concurrent use, and should return quickly.
+//
+// SetInfoLog replaces the setting of SetInfoLogFile.
+//
+//  Default: nil
+func (o *Options) SetInfoLog(fn func(msg string)) {
+	o.infoLogFile = ""
+	if fn == nil {
+		C.leveldb_options_set_info_log(o.opt, nil)
+		o.infoLog = nil
+		return
+	}
+	o.infoLog = newInfoLog(fn)
+	C.leveldb_options_set_info_log(o.opt, o.infoLog.log)
+}
+
+// clearInfoLog removes the setting of SetInfoLog.
+func (o *Options) clearInfoLog() {
+	C.leveldb_options_set_info_log(o.opt, nil)
+	o.infoLog = nil
+}
+
+// infoLog is the LevelDB logger installed by Options.SetInfoLog. It is
+// referenced by the Options and by the DBs opened with them, and freed by
+// its finalizer once none of them holds it anymore.
+type infoLog struct {
+	log    *C.leveldb_logger_t
+	handle uintptr
+}
+
+func newInfoLog(fn func(msg string)) *infoLog {
+	// The handle registry holds fn but not l, so that l can be finalized.
+	l := &infoLog{handle: newHandle(fn)}
+	l.log = C.goleveldb_logger_create(C.uintptr_t(l.handle))
+	runtime.SetFinalizer(l, (*infoLog).destroy)
+	return l
+}
+
+func (l *infoLog) destroy() {
+	C.goleveldb_logger_destroy(l.log)
+	l.log = nil
+	deleteHandle(l.handle)
+}
+
+//export goleveldbInfoLog
+func goleveldbInfoLog(h C.uintptr_t, msg *C.char, n C.int) {
+	if fn, ok := handleValue(uintptr(h)).(func(msg string)); ok {
+		fn(C.GoStringN(msg, n))
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !goleveldb_cxx
+
+package goleveldb
+
+// Any internal progress/error information generated by the db will
+// be passed to fn, one line at a time, if it is non-nil, or only written to
+// a file stored in the same directory as the DB contents if fn is nil.
+//
+// The C API can't replace that LOG file: the DBs opened with o follow it
+// and pass fn the lines added, without their timestamp and thread header,
+// within a fraction of a second; the lines logged up to Close are passed
+// before it returns. fn is called from a single goroutine per DB. Built
+// with the goleveldb_cxx tag, fn is installed as the logger of LevelDB
+// instead, see the README.
+//
+// SetInfoLog replaces the setting of SetInfoLogFile.
+//
+//  Default: nil
+func (o *Options) SetInfoLog(fn func(msg string)) {
+	o.infoLogFile = ""
+	o.infoLogFunc = fn
+}
+
+// clearInfoLog removes the setting of SetInfoLog.
+func (o *Options) clearInfoLog() {
+	o.infoLogFunc = nil
+}
+
+// infoLog stands for the LevelDB logger of the goleveldb_cxx build, which
+// this build doesn't have.
+type infoLog struct{}
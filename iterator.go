@@ -1,11 +1,49 @@
 package goleveldb
 
-// #cgo LDFLAGS: -lleveldb
-// #include "leveldb/c.h"
+/*
+#cgo LDFLAGS: -lleveldb
+#include <string.h>
+#include "leveldb/c.h"
+
+// Copies the entries from the current position of the iterator into buf,
+// keys and values back to back, and their lengths into lens, moving the
+// iterator along. Stops after max entries or when the next one doesn't fit
+// in the cap bytes of buf; if the first one doesn't fit, its lengths are
+// stored in lens to tell the size needed. Returns the number of entries
+// copied.
+static int goleveldb_iter_next_batch(leveldb_iterator_t* it, int max,
+	char* buf, size_t cap, size_t* lens) {
+
+	int n = 0;
+	size_t used = 0;
+	while (n < max && leveldb_iter_valid(it)) {
+		size_t klen, vlen;
+		const char* k = leveldb_iter_key(it, &klen);
+		const char* v = leveldb_iter_value(it, &vlen);
+		if (used + klen + vlen > cap) {
+			if (n == 0) {
+				lens[0] = klen;
+				lens[1] = vlen;
+			}
+			break;
+		}
+		memcpy(buf + used, k, klen);
+		used += klen;
+		memcpy(buf + used, v, vlen);
+		used += vlen;
+		lens[2*n] = klen;
+		lens[2*n+1] = vlen;
+		n++;
+		leveldb_iter_next(it);
+	}
+	return n;
+}
+*/
 import "C"
 
 import (
-	"errors"
+	"bytes"
+	"runtime"
 	"unsafe"
 )
 
@@ -40,12 +78,117 @@ import (
 // is no longer needed by the program.
 type Iterator struct {
 	iter *C.leveldb_iterator_t
+	db   *DB
+	snap *Snapshot // snapshot of the ReadOptions the iterator was created with
+
+	// Bounds of an iterator created by NewRangeIterator or
+	// NewPrefixIterator, nil if unbounded. A prefix iterator of a DB with a
+	// custom comparator only has its prefix.
+	start, limit []byte
+	prefix       []byte
+
+	reverse bool // created by NewReverseIterator
+
+	batchBuf []byte // buffer NextBatch has the entries copied into
+
+	err error // ErrClosed for iterators created after the DB was closed
 }
 
 // Valid returns false only when an Iterator has iterated past either the
-// first or the last key in the database.
+// first or the last key in the database, or out of the range of an
+// Iterator returned by NewRangeIterator.
 func (it *Iterator) Valid() bool {
-	return uchar2bool(C.leveldb_iter_valid(it.iter))
+	if it.iter == nil || !uchar2bool(C.leveldb_iter_valid(it.iter)) {
+		return false
+	}
+	if it.start == nil && it.limit == nil && it.prefix == nil {
+		return true
+	}
+
+	var klen C.size_t
+	key := cBytes(C.leveldb_iter_key(it.iter, &klen), klen)
+	return (it.start == nil || it.db.cmp(key, it.start) >= 0) && it.beforeEnd(key)
+}
+
+// beforeEnd reports whether key is before the end of the range of the
+// iterator.
+func (it *Iterator) beforeEnd(key []byte) bool {
+	return (it.limit == nil || it.db.cmp(key, it.limit) < 0) &&
+		(it.prefix == nil || bytes.HasPrefix(key, it.prefix))
+}
+
+// nextBatchBufferSize is the size of the buffer NextBatch has the entries
+// copied into, unless an entry needs a larger one.
+const nextBatchBufferSize = 64 << 10
+
+// nextBatchChunk is the most entries NextBatch copies per cgo call.
+const nextBatchChunk = 1024
+
+// NextBatch returns up to max entries from the current position of the
+// iterator, moving it past them, as max calls to Key, Value and Next would.
+// done reports whether the iterator went past the last entry, after which
+// Valid returns false.
+//
+// The entries are copied in cgo calls of up to 1024 entries each, which
+// makes scans much faster than with Next when the entries are small. Each
+// key and its value share a single allocation, sized for them, and may be
+// retained. A reverse Iterator copies the entries one at a time.
+func (it *Iterator) NextBatch(max int) (keys, values [][]byte, done bool) {
+	if !it.Valid() {
+		return nil, nil, true
+	}
+	if max <= 0 {
+		return nil, nil, false
+	}
+	if it.reverse {
+		for ; len(keys) < max && it.Valid(); it.Next() {
+			keys = append(keys, it.Key())
+			values = append(values, it.Value())
+		}
+		return keys, values, !it.Valid()
+	}
+
+	chunk := nextBatchChunk
+	if max < chunk {
+		chunk = max
+	}
+	lens := make([]C.size_t, 2*chunk)
+	if it.batchBuf == nil {
+		it.batchBuf = make([]byte, nextBatchBufferSize)
+	}
+	buf := it.batchBuf
+	for len(keys) < max {
+		if max-len(keys) < chunk {
+			chunk = max - len(keys)
+		}
+		n := int(C.goleveldb_iter_next_batch(it.iter, C.int(chunk),
+			(*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)), &lens[0]))
+		if n == 0 {
+			if !uchar2bool(C.leveldb_iter_valid(it.iter)) {
+				break
+			}
+			// The next entry needs a larger buffer, not kept for the next
+			// calls.
+			buf = make([]byte, int(lens[0]+lens[1]))
+			continue
+		}
+
+		off := 0
+		for i := 0; i < n; i++ {
+			klen, vlen := int(lens[2*i]), int(lens[2*i+1])
+			if !it.beforeEnd(buf[off : off+klen]) {
+				// Out of the range, and so is the iterator now.
+				return keys, values, true
+			}
+			entry := make([]byte, klen+vlen)
+			copy(entry, buf[off:])
+			off += klen + vlen
+			keys = append(keys, entry[:klen:klen])
+			values = append(values, entry[klen:])
+		}
+		buf = it.batchBuf
+	}
+	return keys, values, !it.Valid()
 }
 
 // Key returns a copy the key in the database the iterator currently holds.
@@ -81,44 +224,131 @@ func (it *Iterator) Value() []byte {
 	return C.GoBytes(unsafe.Pointer(vdata), C.int(vlen))
 }
 
+// entrySize returns the sizes of the current key and value, without copying
+// them.
+func (it *Iterator) entrySize() (keyLen, valueLen int) {
+	var klen, vlen C.size_t
+	C.leveldb_iter_key(it.iter, &klen)
+	C.leveldb_iter_value(it.iter, &vlen)
+	return int(klen), int(vlen)
+}
+
 // Next moves the iterator to the next sequential key in the database, as
 // defined by the Comparator in the ReadOptions used to create this Iterator.
+// A reverse Iterator moves to the previous key instead.
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Next() {
+	if it.reverse {
+		C.leveldb_iter_prev(it.iter)
+		return
+	}
 	C.leveldb_iter_next(it.iter)
 }
 
 // Prev moves the iterator to the previous sequential key in the database, as
 // defined by the Comparator in the ReadOptions used to create this Iterator.
+// A reverse Iterator moves to the next key instead.
 //
 // If Valid returns false, this method will panic.
 func (it *Iterator) Prev() {
+	if it.reverse {
+		C.leveldb_iter_next(it.iter)
+		return
+	}
 	C.leveldb_iter_prev(it.iter)
 }
 
 // SeekToFirst moves the iterator to the first key in the database, as defined
-// by the Comparator in the ReadOptions used to create this Iterator.
+// by the Comparator in the ReadOptions used to create this Iterator, or in
+// the range of an Iterator returned by NewRangeIterator. A reverse Iterator
+// moves to the last key instead.
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) SeekToFirst() {
+	if it.reverse {
+		it.seekToLast()
+		return
+	}
+	it.seekToFirst()
+}
+
+func (it *Iterator) seekToFirst() {
+	if it.iter == nil {
+		return
+	}
+	if it.start != nil {
+		it.seek(it.start)
+		return
+	}
 	C.leveldb_iter_seek_to_first(it.iter)
+	if it.prefix != nil && !it.db.bytewise {
+		// A prefix iterator of a DB with a custom comparator.
+		for uchar2bool(C.leveldb_iter_valid(it.iter)) && !it.atPrefix() {
+			C.leveldb_iter_next(it.iter)
+		}
+	}
 }
 
 // SeekToLast moves the iterator to the last key in the database, as defined
-// by the Comparator in the ReadOptions used to create this Iterator.
+// by the Comparator in the ReadOptions used to create this Iterator, or in
+// the range of an Iterator returned by NewRangeIterator. A reverse Iterator
+// moves to the first key instead.
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) SeekToLast() {
+	if it.reverse {
+		it.seekToFirst()
+		return
+	}
+	it.seekToLast()
+}
+
+func (it *Iterator) seekToLast() {
+	if it.iter == nil {
+		return
+	}
+	if it.limit != nil {
+		it.seek(it.limit)
+		if uchar2bool(C.leveldb_iter_valid(it.iter)) {
+			C.leveldb_iter_prev(it.iter)
+			return
+		}
+	}
 	C.leveldb_iter_seek_to_last(it.iter)
+	if it.prefix != nil && !it.db.bytewise {
+		// A prefix iterator of a DB with a custom comparator.
+		for uchar2bool(C.leveldb_iter_valid(it.iter)) && !it.atPrefix() {
+			C.leveldb_iter_prev(it.iter)
+		}
+	}
+}
+
+// atPrefix reports whether the valid iterator is at a key starting with
+// its prefix.
+func (it *Iterator) atPrefix() bool {
+	var klen C.size_t
+	return bytes.HasPrefix(cBytes(C.leveldb_iter_key(it.iter, &klen), klen), it.prefix)
 }
 
 // Seek moves the iterator the position of the key given or, if the key
 // doesn't exist, the next key that does exist in the database. If the key
 // doesn't exist, and there is no next key, the Iterator becomes invalid.
+// A reverse Iterator moves to the previous key instead, as SeekForPrev.
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) Seek(key []byte) {
+	if it.reverse {
+		it.seekForPrev(key)
+		return
+	}
+	it.seek(key)
+}
+
+func (it *Iterator) seek(key []byte) {
+	if it.iter == nil {
+		return
+	}
 	var keyPtr *C.char
 	var keyLen = len(key)
 
@@ -130,23 +360,123 @@ func (it *Iterator) Seek(key []byte) {
 	C.leveldb_iter_seek(it.iter, keyPtr, C.size_t(keyLen))
 }
 
+// SeekChecked is Seek, returning the error the iterator ran into while
+// seeking, if any, rather than leaving it to be found by Error. LevelDB
+// may skip the blocks it fails to read: the iterator can be valid, at a key
+// past the one sought, along with an error.
+//
+// This method is safe to call when Valid returns false.
+func (it *Iterator) SeekChecked(key []byte) error {
+	it.Seek(key)
+	return it.Error()
+}
+
+// SeekForPrev moves the iterator to the position of the key given or, if
+// the key doesn't exist, the previous key that does exist in the database.
+// If there is no such key, the Iterator becomes invalid. A reverse Iterator
+// moves to the next key instead, as Seek.
+//
+// This method is safe to call when Valid returns false.
+func (it *Iterator) SeekForPrev(key []byte) {
+	if it.reverse {
+		it.seek(key)
+		return
+	}
+	it.seekForPrev(key)
+}
+
+func (it *Iterator) seekForPrev(key []byte) {
+	if it.iter == nil {
+		return
+	}
+	if it.limit != nil && it.db.cmp(key, it.limit) >= 0 {
+		it.seekToLast()
+		return
+	}
+
+	it.seek(key)
+	if !uchar2bool(C.leveldb_iter_valid(it.iter)) {
+		C.leveldb_iter_seek_to_last(it.iter)
+		return
+	}
+	var klen C.size_t
+	if it.db.cmp(cBytes(C.leveldb_iter_key(it.iter, &klen), klen), key) > 0 {
+		C.leveldb_iter_prev(it.iter)
+	}
+}
+
 // Error returns an IteratorError from LevelDB if it had one during
 // iteration.
 //
 // This method is safe to call when Valid returns false.
 func (it *Iterator) Error() error {
+	if it.iter == nil {
+		return it.err
+	}
 	var errStr *C.char
 	C.leveldb_iter_get_error(it.iter, &errStr)
 	if errStr != nil {
 		gs := C.GoString(errStr)
 		C.leveldb_free(unsafe.Pointer(errStr))
-		return errors.New(gs)
+		return newLevelDBError(gs)
 	}
 	return nil
 }
 
+// GetError is the same as Error.
+func (it *Iterator) GetError() error {
+	return it.Error()
+}
+
+// Reset makes the iterator read with ro, as if it had just been returned by
+// DB.NewIterator(ro) on the DB that created it.
+//
+// When ro reads from the same snapshot the iterator was created with, the
+// underlying C iterator is kept and nothing is allocated; otherwise it is
+// replaced by a new one. Reuse needs an explicit snapshot: without one, a
+// new iterator would see the latest state of the database, which an
+// existing iterator can't catch up to. Only the snapshot of ro is compared,
+// so its other settings take effect only when the iterator is replaced. An
+// iterator that has an error is always replaced.
+//
+// Like a new Iterator, the position of the iterator after Reset is
+// unspecified: call one of the Seek methods before using it.
+//
+// Set the ReadOptions default if ro == nil
+func (it *Iterator) Reset(ro *ReadOptions) {
+	if ro == nil {
+		ro = it.db.defaultROpt
+	}
+	released := ro.snap != nil && ro.snap.Released()
+	if ro.snap != nil && ro.snap == it.snap && !released && it.Error() == nil {
+		return
+	}
+
+	if it.iter != nil {
+		C.leveldb_iter_destroy(it.iter)
+		it.iter = nil
+	}
+	it.snap = ro.snap
+	if it.db.db == nil {
+		it.err = ErrClosed
+		return
+	}
+	if released {
+		it.err = ErrSnapshotReleased
+		return
+	}
+	it.iter = C.leveldb_create_iterator(it.db.db, ro.opt)
+	runtime.KeepAlive(ro)
+	it.err = nil
+}
+
 // Close deallocates the given Iterator, freeing the underlying C struct.
 func (it *Iterator) Close() {
-	C.leveldb_iter_destroy(it.iter)
-	it.iter = nil
+	if it.iter != nil {
+		C.leveldb_iter_destroy(it.iter)
+		it.iter = nil
+	}
+	it.batchBuf = nil
+	it.err = nil
+	trackFree(it)
 }
@@ -0,0 +1,104 @@
+package goleveldb
+
+import (
+	"sync"
+)
+
+// An IteratorPool keeps Iterators over a fixed snapshot of a DB for reuse,
+// saving the creation of a new one for each short scan.
+//
+// LevelDB iterators can't be moved to a newer view of the database, so all
+// the iterators of a pool read from the same snapshot: the one of the
+// ReadOptions the pool was created with, or else a snapshot the pool takes
+// when it is created and releases when it is closed. Create a new pool to
+// see later updates.
+//
+// An IteratorPool is safe for concurrent use.
+type IteratorPool struct {
+	db      *DB
+	ro      *ReadOptions
+	release func() // releases ro and the snapshot, if the pool took it
+
+	mu     sync.Mutex
+	free   []*Iterator
+	closed bool
+}
+
+// NewIteratorPool returns an IteratorPool of iterators reading with ro.
+//
+// The settings of ro are copied: ro may be destroyed once NewIteratorPool
+// returns, but its snapshot, if any, must not be released before the pool
+// is closed.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewIteratorPool(ro *ReadOptions) *IteratorPool {
+	if ro == nil {
+		ro = db.defaultROpt
+	}
+	p := &IteratorPool{db: db}
+	if ro.snap != nil {
+		p.ro = ro.Clone()
+		p.release = p.ro.Destroy
+	} else {
+		p.ro, p.release = db.snapshotReadOptions(ro)
+	}
+	return p
+}
+
+// Get returns an Iterator of the pool, reusing one put back if there is
+// one. Its position is unspecified: call one of the Seek methods before
+// using it.
+//
+// Give the Iterator back with Put rather than closing it. Get must not be
+// called after Close.
+func (p *IteratorPool) Get() *Iterator {
+	p.mu.Lock()
+	if n := len(p.free); n > 0 {
+		it := p.free[n-1]
+		p.free[n-1] = nil
+		p.free = p.free[:n-1]
+		p.mu.Unlock()
+		return it
+	}
+	p.mu.Unlock()
+	return p.db.NewIterator(p.ro)
+}
+
+// Put returns an Iterator obtained from Get to the pool. it must not be
+// used afterwards.
+//
+// Iterators that have an error, or that are put back after the pool was
+// closed, are closed instead.
+func (p *IteratorPool) Put(it *Iterator) {
+	if it.Error() == nil {
+		p.mu.Lock()
+		if !p.closed {
+			p.free = append(p.free, it)
+			it = nil
+		}
+		p.mu.Unlock()
+	}
+	if it != nil {
+		it.Close()
+	}
+}
+
+// Close closes the iterators in the pool, and releases the snapshot the
+// pool took, if any. The iterators still in use are closed when they are
+// put back, and must be before the DB is closed.
+func (p *IteratorPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	free := p.free
+	p.free = nil
+	p.mu.Unlock()
+
+	for _, it := range free {
+		it.Close()
+	}
+	p.release()
+}
@@ -0,0 +1,108 @@
+package goleveldb
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// leakTracking is non-zero while leak tracking is enabled.
+var leakTracking int32
+
+var leaks struct {
+	sync.Mutex
+	live map[interface{}]*allocation
+	seq  uint64
+}
+
+// allocation records where a resource was allocated.
+type allocation struct {
+	kind string
+	seq  uint64
+	pcs  []uintptr
+}
+
+// EnableLeakTracking turns on or off the tracking of the resources that
+// must be explicitly released: DB, Options, ReadOptions, WriteOptions,
+// WriteBatch, Cache, Env, Comparator, FilterPolicy, Iterator, Snapshot and
+// CValue.
+//
+// While it is on, the stack of each allocation is recorded until the
+// resource is destroyed, closed or released, and ReportLeaks lists the
+// resources still alive. Turning it off forgets everything recorded.
+//
+// Tracking is meant for debugging and tests: it slows allocations down, and
+// keeps the tracked resources reachable so that finalizers don't free them.
+func EnableLeakTracking(enable bool) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	if enable {
+		atomic.StoreInt32(&leakTracking, 1)
+		if leaks.live == nil {
+			leaks.live = make(map[interface{}]*allocation)
+		}
+	} else {
+		atomic.StoreInt32(&leakTracking, 0)
+		leaks.live = nil
+	}
+}
+
+// ReportLeaks writes to w the allocation site of every tracked resource
+// that is still alive, oldest first, and returns how many there are.
+//
+// Nothing is tracked unless EnableLeakTracking(true) was called before the
+// allocations.
+func ReportLeaks(w io.Writer) int {
+	leaks.Lock()
+	live := make([]*allocation, 0, len(leaks.live))
+	for _, a := range leaks.live {
+		live = append(live, a)
+	}
+	leaks.Unlock()
+
+	sort.Slice(live, func(i, j int) bool { return live[i].seq < live[j].seq })
+	for _, a := range live {
+		fmt.Fprintf(w, "goleveldb: %s leaked, allocated at:\n", a.kind)
+		frames := runtime.CallersFrames(a.pcs)
+		for {
+			frame, more := frames.Next()
+			fmt.Fprintf(w, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+			if !more {
+				break
+			}
+		}
+	}
+	return len(live)
+}
+
+// trackAlloc records the allocation of resource p, if tracking is on.
+func trackAlloc(p interface{}, kind string) {
+	if atomic.LoadInt32(&leakTracking) == 0 {
+		return
+	}
+
+	pcs := make([]uintptr, 32)
+	pcs = pcs[:runtime.Callers(3, pcs)] // skip Callers, trackAlloc and the constructor
+
+	leaks.Lock()
+	defer leaks.Unlock()
+	if leaks.live != nil {
+		leaks.seq++
+		leaks.live[p] = &allocation{kind: kind, seq: leaks.seq, pcs: pcs}
+	}
+}
+
+// trackFree forgets resource p.
+func trackFree(p interface{}) {
+	if atomic.LoadInt32(&leakTracking) == 0 {
+		return
+	}
+
+	leaks.Lock()
+	delete(leaks.live, p)
+	leaks.Unlock()
+}
@@ -2,10 +2,24 @@ package goleveldb
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -311,6 +325,4374 @@ func TestIterationValidityLimits(t *testing.T) {
 	}
 }
 
+// openTestDB creates a new database at dbname, failing the test if it can't.
+func openTestDB(t testing.TB, dbname string) *DB {
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetErrorIfExists(true)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	return db
+}
+
+func TestBatchGetSorted(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if i%3 != 0 {
+			if err := db.Put(nil, key, []byte(fmt.Sprintf("value%d", i))); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+		}
+		keys = append(keys, key)
+	}
+	// duplicates must not confuse the result
+	keys = append(keys, []byte("key001"), []byte("key002"), []byte("missing"))
+
+	for round := 0; round < 3; round++ {
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		order := fmt.Sprintf("%q", keys)
+
+		values, err := db.BatchGetSorted(nil, keys)
+		if err != nil {
+			t.Fatalf("BatchGetSorted failed: %v", err)
+		}
+		if got := fmt.Sprintf("%q", keys); got != order {
+			t.Errorf("BatchGetSorted reordered the caller's keys")
+		}
+
+		want := 0
+		for _, key := range keys {
+			value, err := db.Get(nil, key)
+			if err == ErrNotFound {
+				if v, ok := values[string(key)]; ok {
+					t.Errorf("missing key %q returned value %q", key, v)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if !bytes.Equal(values[string(key)], value) {
+				t.Errorf("key %q: expected %q, got %q", key, value, values[string(key)])
+			}
+			want++
+		}
+		// the two duplicated keys both exist
+		if len(values) != want-2 {
+			t.Errorf("expected %d values, got %d", want-2, len(values))
+		}
+	}
+}
+
+// benchmarkScatteredGets builds a database much larger than its block cache
+// and returns it along with a scattered set of keys to look up.
+func benchmarkScatteredGets(b *testing.B) (db *DB, keys [][]byte, cleanup func()) {
+	dbname := tempDir(b)
+	cache := NewLRUCache(64 << 10)
+	options := NewOptions()
+	options.SetCreateIfMissing(true)
+	options.SetCache(cache)
+	options.SetCompression(NoCompression)
+	db, err := Open(dbname, options)
+	if err != nil {
+		b.Fatalf("Database could not be opened: %v", err)
+	}
+
+	const n = 200000
+	value := bytes.Repeat([]byte("v"), 100)
+	wb := NewWriteBatch()
+	for i := 0; i < n; i++ {
+		wb.Put([]byte(fmt.Sprintf("key%08d", i)), value)
+		if i%1000 == 999 {
+			if err := db.Write(nil, wb); err != nil {
+				b.Fatalf("Write failed: %v", err)
+			}
+			wb.Clear()
+		}
+	}
+	wb.Destroy()
+	db.CompactRange(nil, nil)
+
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key%08d", rand.Intn(n))))
+	}
+	return db, keys, func() {
+		db.Close()
+		options.Destroy()
+		cache.Destroy()
+		deleteDBDirectory(b, dbname)
+	}
+}
+
+func BenchmarkGetUnsorted(b *testing.B) {
+	db, keys, cleanup := benchmarkScatteredGets(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			if _, err := db.Get(nil, key); err != nil {
+				b.Fatalf("Get failed: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchGetSorted(b *testing.B) {
+	db, keys, cleanup := benchmarkScatteredGets(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.BatchGetSorted(nil, keys); err != nil {
+			b.Fatalf("BatchGetSorted failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkMultiGet is BenchmarkBatchGetSorted with MultiGet, which reads
+// the keys in the order given.
+func BenchmarkMultiGet(b *testing.B) {
+	db, keys, cleanup := benchmarkScatteredGets(b)
+	defer cleanup()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, errs := db.MultiGet(nil, keys)
+		for _, err := range errs {
+			if err != nil {
+				b.Fatalf("MultiGet failed: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	db, keys, cleanup := benchmarkScatteredGets(b)
+	defer cleanup()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Get(nil, keys[i%len(keys)]); err != nil {
+			b.Fatalf("Get failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkHasLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(db *DB, key []byte) error {
+		_, err := db.Has(nil, key)
+		return err
+	})
+}
+
+func BenchmarkGetLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(db *DB, key []byte) error {
+		_, err := db.Get(nil, key)
+		return err
+	})
+}
+
+// benchmarkLargeValue reads a key with a 1MB value.
+func benchmarkLargeValue(b *testing.B, read func(db *DB, key []byte) error) {
+	dbname := tempDir(b)
+	defer deleteDBDirectory(b, dbname)
+	db := openTestDB(b, dbname)
+	defer db.Close()
+
+	key := []byte("large")
+	db.Put(nil, key, bytes.Repeat([]byte("v"), 1<<20))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := read(db, key); err != nil {
+			b.Fatalf("read failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkWriteBatchPool(b *testing.B) {
+	var pool WriteBatchPool
+	benchmarkWriteBatches(b, pool.Get, pool.Put)
+}
+
+func BenchmarkWriteBatchNew(b *testing.B) {
+	benchmarkWriteBatches(b, NewWriteBatch, (*WriteBatch).Destroy)
+}
+
+// benchmarkWriteBatches fills and releases batches of 10 small updates.
+func benchmarkWriteBatches(b *testing.B, get func() *WriteBatch, put func(*WriteBatch)) {
+	key, value := []byte("key"), []byte("value")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		wb := get()
+		for j := 0; j < 10; j++ {
+			wb.Put(key, value)
+		}
+		put(wb)
+	}
+}
+
+func BenchmarkGetMulti(b *testing.B) {
+	benchmarkGetMulti(b, func(db *DB, keys [][]byte) { db.GetMulti(nil, keys) })
+}
+
+func BenchmarkGetMultiSerial(b *testing.B) {
+	benchmarkGetMulti(b, func(db *DB, keys [][]byte) { db.MultiGet(nil, keys) })
+}
+
+// benchmarkGetMulti reads 100 random keys of 4KB values per iteration.
+func benchmarkGetMulti(b *testing.B, getMulti func(db *DB, keys [][]byte)) {
+	dbname := tempDir(b)
+	defer deleteDBDirectory(b, dbname)
+	db := openTestDB(b, dbname)
+	defer db.Close()
+
+	value := bytes.Repeat([]byte("v"), 4<<10)
+	for i := 0; i < 10000; i++ {
+		db.Put(nil, []byte(strconv.Itoa(i)), value)
+	}
+	db.CompactRange(nil, nil)
+	keys := make([][]byte, 100)
+	for i, n := range rand.New(rand.NewSource(1)).Perm(10000)[:100] {
+		keys[i] = []byte(strconv.Itoa(n))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getMulti(db, keys)
+	}
+}
+
+func BenchmarkGetInto(b *testing.B) {
+	db, keys, cleanup := benchmarkScatteredGets(b)
+	defer cleanup()
+	buf := make([]byte, 128)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.GetInto(nil, keys[i%len(keys)], buf); err != nil {
+			b.Fatalf("GetInto failed: %v", err)
+		}
+	}
+}
+
+type spyDestroyer struct {
+	Destroyer
+	name  string
+	order *[]string
+}
+
+func (s *spyDestroyer) Destroy() {
+	s.Destroyer.Destroy()
+	*s.order = append(*s.order, s.name)
+}
+
+func TestAddCloser(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	var destroyed []string
+	cache := &spyDestroyer{NewLRUCache(1 << 20), "cache", &destroyed}
+	filter := &spyDestroyer{NewBloomFilterPolicy(10), "filter", &destroyed}
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetCache(cache.Destroyer.(*Cache))
+	options.SetBloomFilterPolicy(filter.Destroyer.(*FilterPolicy))
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	db.AddCloser(cache)
+	db.AddCloser(filter)
+
+	if err := db.Put(nil, []byte("foo"), []byte("bar")); err != nil {
+		t.Errorf("Put failed: %v", err)
+	}
+	if len(destroyed) != 0 {
+		t.Errorf("closers destroyed before Close: %v", destroyed)
+	}
+
+	db.Close()
+	if fmt.Sprint(destroyed) != "[filter cache]" {
+		t.Errorf("expected closers destroyed in LIFO order, got %v", destroyed)
+	}
+}
+
+func TestBuildKeyBitmap(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	present := map[int]bool{}
+	for i := 0; i < 300; i += 7 {
+		present[i] = true
+		db.Put(nil, []byte(fmt.Sprintf("user:%d", i)), nil)
+	}
+	db.Put(nil, []byte("user:notanumber"), nil)
+	db.Put(nil, []byte("usera"), nil)
+	db.Put(nil, []byte("group:3"), nil)
+
+	bitmap, err := db.BuildKeyBitmap(nil, []byte("user:"), func(key []byte) (int, bool) {
+		i, err := strconv.Atoi(string(key[len("user:"):]))
+		return i, err == nil
+	})
+	if err != nil {
+		t.Fatalf("BuildKeyBitmap failed: %v", err)
+	}
+	if bitmap.Count() != len(present) {
+		t.Errorf("expected %d bits set, got %d", len(present), bitmap.Count())
+	}
+	for i := 0; i < 400; i++ {
+		if bitmap.Test(i) != present[i] {
+			t.Errorf("bit %d: expected %v, got %v", i, present[i], bitmap.Test(i))
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "goleveldb: negative Bitset index" {
+				t.Errorf("Set(-1) panicked with %v", r)
+			}
+		}()
+		bitmap.Set(-1)
+	}()
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestResilientDBRepairsCorruption(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetBlockSize(256)
+	// Makes the repair verify checksums and drop the corrupted block.
+	options.SetParanoidChecks(true)
+
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%04d", i)))
+	}
+	db.CompactRange(nil, nil)
+	db.Close()
+	if corruptTableFiles(t, dbname) == 0 {
+		t.Fatalf("no table files to corrupt")
+	}
+
+	rdb, err := OpenResilient(dbname, options, time.Hour)
+	if err != nil {
+		t.Fatalf("OpenResilient failed: %v", err)
+	}
+	defer rdb.Close()
+	logger := &recordingLogger{}
+	rdb.SetLogger(logger)
+
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+
+	// key0000 lives in the corrupted block, which the repair drops.
+	if _, err := rdb.Get(ro, []byte("key0000")); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a key of the dropped block, got %v", err)
+	}
+	if !strings.Contains(strings.Join(logger.lines, "\n"), "repaired and reopened") {
+		t.Errorf("expected a repair to be logged, got %q", logger.lines)
+	}
+	CheckResilientGet(t, rdb, ro, []byte("key0999"), []byte("value0999"))
+
+	// The reopened database is fully usable.
+	if err := rdb.Put(nil, []byte("fresh"), []byte("data")); err != nil {
+		t.Errorf("Put after repair failed: %v", err)
+	}
+	CheckResilientGet(t, rdb, ro, []byte("fresh"), []byte("data"))
+}
+
+func TestResilientDBRetriesFailedReopen(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetBlockSize(256)
+	options.SetParanoidChecks(true)
+
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%04d", i)))
+	}
+	db.CompactRange(nil, nil)
+	db.Close()
+	if corruptTableFiles(t, dbname) == 0 {
+		t.Fatalf("no table files to corrupt")
+	}
+
+	const cooldown = 100 * time.Millisecond
+	rdb, err := OpenResilient(dbname, options, cooldown)
+	if err != nil {
+		t.Fatalf("OpenResilient failed: %v", err)
+	}
+	defer rdb.Close()
+	logger := &recordingLogger{}
+	rdb.SetLogger(logger)
+
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+
+	// Makes the reopen after the repair fail.
+	options.SetErrorIfExists(true)
+	if _, err := rdb.Get(ro, []byte("key0000")); !isCorruption(err) {
+		t.Errorf("expected the corruption when the reopen fails, got %v", err)
+	}
+	if _, err := rdb.Get(ro, []byte("key0999")); err == nil {
+		t.Errorf("Get succeeded without a DB")
+	}
+
+	options.SetErrorIfExists(false)
+	time.Sleep(cooldown)
+	CheckResilientGet(t, rdb, ro, []byte("key0999"), []byte("value0999"))
+	if !strings.Contains(strings.Join(logger.lines, "\n"), "repaired and reopened") {
+		t.Errorf("expected a reopen to be logged, got %q", logger.lines)
+	}
+
+	rdb.Close()
+	if _, err := rdb.Get(ro, []byte("key0999")); err == nil {
+		t.Errorf("Get succeeded after Close")
+	}
+}
+
+func CheckResilientGet(t *testing.T, rdb *ResilientDB, ro *ReadOptions, key, expected []byte) {
+	value, err := rdb.Get(ro, key)
+	if err != nil {
+		t.Errorf("Get %q failed: %v", key, err)
+	}
+	if !bytes.Equal(value, expected) {
+		t.Errorf("Get %q: expected %q, got %q", key, expected, value)
+	}
+}
+
+func TestVerifyRange(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	// Two tables holding disjoint key ranges: "a..." then "b...".
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetBlockSize(256)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	for _, prefix := range []string{"a", "b"} {
+		for i := 0; i < 1000; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("%s%04d", prefix, i)), []byte("value"))
+		}
+		db.CompactRange([]byte(prefix), []byte(prefix+"\xff"))
+	}
+	if err := db.Verify(); err != nil {
+		t.Errorf("Verify on a healthy db failed: %v", err)
+	}
+	db.Close()
+
+	tables := tableFiles(dbname)
+	if len(tables) != 2 {
+		t.Fatalf("expected 2 tables, got %v", tables)
+	}
+	// Table files are numbered in creation order. Leave the first block of
+	// the "b" table alone, reading [a, b) has to peek into it.
+	info, err := os.Stat(tables[1])
+	if err != nil {
+		t.Fatalf("Unable to stat %s: %v", tables[1], err)
+	}
+	corruptFile(t, tables[1], info.Size()/2)
+
+	db, err = Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Unable to reopen db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.VerifyRange(Range{[]byte("a"), []byte("b")}); err != nil {
+		t.Errorf("VerifyRange on the healthy range failed: %v", err)
+	}
+	if err := db.VerifyRange(Range{[]byte("b"), nil}); !isCorruption(err) {
+		t.Errorf("VerifyRange on the corrupted range: expected a corruption, got %v", err)
+	}
+	if err := db.Verify(); !isCorruption(err) {
+		t.Errorf("Verify: expected a corruption, got %v", err)
+	}
+}
+
+func TestSlowOpLogging(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	// Each reading of the clock advances it by step.
+	var now time.Time
+	var step time.Duration
+	timeNow = func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+	defer func() { timeNow = time.Now }()
+
+	logger := &recordingLogger{}
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetSlowOpThreshold(100 * time.Millisecond)
+	options.SetSlowOpLogger(logger)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	defer db.Close()
+
+	step = time.Millisecond
+	db.Put(nil, []byte("fast"), []byte("value"))
+	if len(logger.lines) != 0 {
+		t.Errorf("fast Put was logged: %q", logger.lines)
+	}
+
+	step = time.Second
+	db.Get(nil, []byte("slow"))
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected the slow Get to be logged, got %q", logger.lines)
+	}
+	if !strings.Contains(logger.lines[0], `Get of key "slow" took 1s`) {
+		t.Errorf("unexpected log line %q", logger.lines[0])
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if db.Path() != dbname {
+		t.Errorf("Path: expected %s, got %s", dbname, db.Path())
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(dbname, fmt.Sprintf("LOG.old.%d", i))
+		if err := os.WriteFile(name, []byte("log"), 0644); err != nil {
+			t.Fatalf("Unable to create %s: %v", name, err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatalf("Unable to set times of %s: %v", name, err)
+		}
+	}
+
+	removed, err := db.PruneLogs(2)
+	if err != nil {
+		t.Fatalf("PruneLogs failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("expected 3 logs removed, got %d", removed)
+	}
+	left, _ := filepath.Glob(filepath.Join(dbname, "LOG.old*"))
+	sort.Strings(left)
+	want := []string{filepath.Join(dbname, "LOG.old.3"), filepath.Join(dbname, "LOG.old.4")}
+	if fmt.Sprint(left) != fmt.Sprint(want) {
+		t.Errorf("expected %v left, got %v", want, left)
+	}
+	if _, err := os.Stat(filepath.Join(dbname, "LOG")); err != nil {
+		t.Errorf("current LOG is gone: %v", err)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	destname := tempDir(t)
+	defer deleteDBDirectory(t, destname)
+
+	db := openTestDB(t, dbname)
+	defer db.Close()
+	for i := 0; i < 2500; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%05d", rand.Intn(100000))), []byte(fmt.Sprintf("value%d", i)))
+	}
+	db.Put(nil, nil, []byte("empty key"))
+	db.Put(nil, []byte("empty value"), nil)
+
+	if err := db.CopyTo(destname, nil); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	// the source stays usable
+	if err := db.Put(nil, []byte("after"), []byte("copy")); err != nil {
+		t.Errorf("Put after CopyTo failed: %v", err)
+	}
+	db.Delete(nil, []byte("after"))
+
+	options := NewOptions()
+	defer options.Destroy()
+	clone, err := Open(destname, options)
+	if err != nil {
+		t.Fatalf("Unable to open the copy: %v", err)
+	}
+	defer clone.Close()
+	if !bytes.Equal(contentHash(t, db), contentHash(t, clone)) {
+		t.Errorf("the copy differs from the source")
+	}
+
+	if err := db.CopyTo(destname, nil); err == nil {
+		t.Errorf("CopyTo over an existing database should have failed")
+	}
+}
+
+func TestCopyToComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	destname := tempDir(t)
+	defer deleteDBDirectory(t, destname)
+
+	reverse := NewComparator("goleveldb.test.reverse", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		db.Put(nil, []byte(key), nil)
+	}
+
+	bytewise := NewOptions()
+	defer bytewise.Destroy()
+	bytewise.SetCreateIfMissing(true)
+	if err := db.CopyTo(destname, bytewise); err == nil {
+		t.Errorf("CopyTo with the default comparator succeeded")
+	}
+
+	if err := db.CopyTo(destname, nil); err != nil {
+		t.Fatalf("CopyTo failed: %v", err)
+	}
+	options.SetCreateIfMissing(false)
+	clone, err := Open(destname, options)
+	if err != nil {
+		t.Fatalf("Unable to open the copy with the comparator of the source: %v", err)
+	}
+	defer clone.Close()
+	var keys []string
+	it := clone.NewIterator(nil)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	if fmt.Sprint(keys) != "[c b a]" {
+		t.Errorf("keys of the copy = %q, want them in reverse order", keys)
+	}
+}
+
+// contentHash returns a hash of all the entries of db.
+func contentHash(t *testing.T, db *DB) []byte {
+	h := sha256.New()
+	it := db.NewIterator(nil)
+	defer it.Close()
+	var n [binary.MaxVarintLen64]byte
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		for _, b := range [][]byte{it.Key(), it.Value()} {
+			h.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+			h.Write(b)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("Unable to hash db: %v", err)
+	}
+	return h.Sum(nil)
+}
+
+func TestRecompactOnError(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openLevel0TestDB(t, dbname)
+	defer db.Close()
+
+	db.RecompactOnError(true)
+	db.RecompactOnError(true)
+
+	fillLevel0(t, db)
+	if db.recompactIfStalled(level0StallTrigger) {
+		t.Errorf("compacted below the stall trigger")
+	}
+	if !db.recompactIfStalled(0) {
+		t.Fatalf("level 0 files should have been compacted")
+	}
+	if n, err := db.NumFilesAtLevel(0); err != nil || n != 0 {
+		t.Errorf("expected no level-0 files after the nudge, got %d (%v)", n, err)
+	}
+
+	db.RecompactOnError(false)
+}
+
+func TestApproximateMemoryUsage(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), bytes.Repeat([]byte("v"), 100))
+	}
+	usage, err := db.ApproximateMemoryUsage()
+	if err == ErrPropertyUnsupported {
+		t.Skipf("LevelDB %d.%d has no memory usage property", db.MajorVersion(), db.MinorVersion())
+	}
+	if err != nil {
+		t.Fatalf("ApproximateMemoryUsage failed: %v", err)
+	}
+	if usage == 0 {
+		t.Errorf("expected a nonzero memory usage with buffered writes")
+	}
+}
+
+// openLevel0TestDB creates a database flushing its memtable often, so that
+// level-0 files are easy to produce.
+func openLevel0TestDB(t *testing.T, dbname string) *DB {
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetWriteBufferSize(64 << 10)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Database could not be opened: %v", err)
+	}
+	return db
+}
+
+// fillLevel0 overwrites the same keys until some files land at level 0.
+func fillLevel0(t *testing.T, db *DB) {
+	value := bytes.Repeat([]byte("x"), 1000)
+	for round := 0; round < 100; round++ {
+		for i := 0; i < 100; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("key%03d", i)), value)
+		}
+		if n, _ := db.NumFilesAtLevel(0); n > 0 {
+			return
+		}
+	}
+	t.Fatalf("no level-0 files were produced")
+}
+
+func TestEntries(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+
+	var scanned []string
+	it := db.NewIterator(nil)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		scanned = append(scanned, string(it.Key())+"="+string(it.Value()))
+	}
+	it.Close()
+
+	var streamed []string
+	stream := db.Entries(nil)
+	for key, value, ok := stream.Next(); ok; key, value, ok = stream.Next() {
+		streamed = append(streamed, string(key)+"="+string(value))
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("stream failed: %v", err)
+	}
+	if _, _, ok := stream.Next(); ok {
+		t.Errorf("exhausted stream returned an entry")
+	}
+	stream.Close()
+	if fmt.Sprint(streamed) != fmt.Sprint(scanned) {
+		t.Errorf("stream and scan differ")
+	}
+
+	// read errors are surfaced
+	db.CompactRange(nil, nil)
+	db.Close()
+	corruptTableFiles(t, dbname)
+	options := NewOptions()
+	defer options.Destroy()
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Unable to reopen db: %v", err)
+	}
+	defer db.Close()
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+	stream = db.Entries(ro)
+	defer stream.Close()
+	for _, _, ok := stream.Next(); ok; _, _, ok = stream.Next() {
+	}
+	if !isCorruption(stream.Err()) {
+		t.Errorf("expected a corruption error, got %v", stream.Err())
+	}
+}
+
+func TestReplacePrefix(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 100; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("p:%03d", i)), []byte("old"))
+	}
+	db.Put(nil, []byte("o"), []byte("outside"))
+	db.Put(nil, []byte("q"), []byte("outside"))
+
+	var entries []KV
+	want := map[string]string{}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("p:%03d", 75+i)
+		entries = append(entries, KV{[]byte(key), []byte("new")})
+		want[key] = "new"
+	}
+	if err := db.ReplacePrefix(nil, []byte("p:"), entries); err != nil {
+		t.Fatalf("ReplacePrefix failed: %v", err)
+	}
+
+	got := map[string]string{}
+	it := db.NewIterator(nil)
+	defer it.Close()
+	for it.Seek([]byte("p:")); it.Valid() && bytes.HasPrefix(it.Key(), []byte("p:")); it.Next() {
+		got[string(it.Key())] = string(it.Value())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v under the prefix, got %v", want, got)
+	}
+	CheckGet(t, "outside the prefix", db, nil, []byte("o"), []byte("outside"))
+	CheckGet(t, "outside the prefix", db, nil, []byte("q"), []byte("outside"))
+
+	err := db.ReplacePrefix(nil, []byte("p:"), []KV{{[]byte("x"), nil}})
+	if err == nil {
+		t.Errorf("ReplacePrefix accepted a key outside the prefix")
+	}
+}
+
+func TestVerifyOrder(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	reverse := NewComparator("goleveldb.test.order", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		db.Put(nil, []byte(key), nil)
+	}
+	if err := db.VerifyOrder(nil); err != nil {
+		t.Errorf("VerifyOrder on a consistent db failed: %v", err)
+	}
+	// Write the keys to a table file, in reverse order.
+	db.CompactRange(nil, nil)
+	db.Close()
+
+	// The same name with the forward ordering: LevelDB opens the database,
+	// and its iterators take the keys after the first one for older
+	// versions of it, and skip them.
+	forward := NewComparator("goleveldb.test.order", bytes.Compare)
+	defer forward.Destroy()
+	options.SetComparator(forward)
+	db, err = Open(dbname, options)
+	if err != nil {
+		t.Fatalf("reopening with the changed comparator failed: %v", err)
+	}
+	defer db.Close()
+
+	var keys []string
+	it := db.NewIterator(nil)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	if fmt.Sprint(keys) != "[c]" {
+		t.Fatalf("keys = %q, expected the keys after c to be skipped", keys)
+	}
+	// The limitation documented on VerifyOrder: the keys out of order
+	// never reach it.
+	if err := db.VerifyOrder(nil); err != nil {
+		t.Errorf("VerifyOrder = %v, the limitation is gone: update its doc and this test", err)
+	}
+}
+
+func TestRateLimitedIterator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	const n, size, rate = 50, 1000, 100000
+	value := bytes.Repeat([]byte("v"), size-len("key00"))
+	for i := 0; i < n; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%02d", i)), value)
+	}
+
+	start := time.Now()
+	it := db.NewRateLimitedIterator(nil, rate)
+	defer it.Close()
+	count := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if !bytes.Equal(it.Key(), []byte(fmt.Sprintf("key%02d", count))) {
+			t.Errorf("unexpected key %q", it.Key())
+		}
+		count++
+	}
+	elapsed := time.Since(start)
+
+	if count != n {
+		t.Errorf("expected %d entries, got %d", n, count)
+	}
+	// The bucket starts empty, everything read must be paid for.
+	if min := time.Duration(n) * size * time.Second / rate; elapsed < min {
+		t.Errorf("scan took %v, expected at least %v", elapsed, min)
+	}
+
+	// NextBatch pays for the entries it returns too.
+	start = time.Now()
+	it2 := db.NewRateLimitedIterator(nil, rate)
+	defer it2.Close()
+	it2.SeekToFirst()
+	keys, _, _ := it2.NextBatch(n)
+	elapsed = time.Since(start)
+	if len(keys) != n {
+		t.Errorf("expected %d entries from NextBatch, got %d", n, len(keys))
+	}
+	if min := time.Duration(n) * size * time.Second / rate; elapsed < min {
+		t.Errorf("batched scan took %v, expected at least %v", elapsed, min)
+	}
+
+	for _, rate := range []int{0, -1} {
+		start := time.Now()
+		it := db.NewRateLimitedIterator(nil, rate)
+		count := 0
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			count++
+		}
+		it.Close()
+		if count != n || time.Since(start) > time.Second {
+			t.Errorf("unlimited scan with rate %d read %d entries in %v", rate, count, time.Since(start))
+		}
+	}
+}
+
+func TestOpenWithFallback(t *testing.T) {
+	missing := tempDir(t)
+	defer deleteDBDirectory(t, missing)
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	openTestDB(t, dbname).Close()
+
+	options := NewOptions()
+	defer options.Destroy()
+	db, path, err := OpenWithFallback([]string{missing, dbname}, options)
+	if err != nil {
+		t.Fatalf("OpenWithFallback failed: %v", err)
+	}
+	db.Close()
+	if path != dbname {
+		t.Errorf("expected %s to be opened, got %s", dbname, path)
+	}
+
+	if _, _, err := OpenWithFallback([]string{missing}, options); err == nil {
+		t.Errorf("OpenWithFallback on a missing db should have failed")
+	}
+	if _, _, err := OpenWithFallback(nil, options); err == nil {
+		t.Errorf("OpenWithFallback without paths should have failed")
+	}
+}
+
+func TestNextKeyAfter(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if _, _, err := db.NextKeyAfter(nil, nil); err != ErrNotFound {
+		t.Errorf("empty db: expected ErrNotFound, got %v", err)
+	}
+
+	db.Put(nil, []byte("a"), []byte("1"))
+	db.Put(nil, []byte("c"), []byte("3"))
+	db.Put(nil, []byte("e"), []byte("5"))
+
+	for _, c := range []struct{ after, next, value string }{
+		{"", "a", "1"},
+		{"a", "c", "3"},
+		{"b", "c", "3"},
+		{"c", "e", "5"},
+	} {
+		next, value, err := db.NextKeyAfter(nil, []byte(c.after))
+		if err != nil || string(next) != c.next || string(value) != c.value {
+			t.Errorf("after %q: expected %q=%q, got %q=%q (%v)", c.after, c.next, c.value, next, value, err)
+		}
+	}
+	for _, after := range []string{"e", "z"} {
+		if _, _, err := db.NextKeyAfter(nil, []byte(after)); err != ErrNotFound {
+			t.Errorf("after %q: expected ErrNotFound, got %v", after, err)
+		}
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	value := make([]byte, 100)
+	for i := 0; i < 10000; i++ {
+		rand.Read(value) // incompressible
+		db.Put(nil, []byte(fmt.Sprintf("key%05d", i)), value)
+	}
+	db.CompactRange(nil, nil)
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+
+	data, err := db.StatsJSON()
+	if err != nil {
+		t.Fatalf("StatsJSON failed: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("StatsJSON output doesn't unmarshal: %v\n%s", err, data)
+	}
+	for _, name := range []string{"version", "level_files", "total_files", "approximate_size", "snapshots", "levels"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("field %s missing from %s", name, data)
+		}
+	}
+
+	var stats DBStats
+	json.Unmarshal(data, &stats)
+	if stats.Version != fmt.Sprintf("%d.%d", db.MajorVersion(), db.MinorVersion()) {
+		t.Errorf("unexpected version %q", stats.Version)
+	}
+	if len(stats.LevelFiles) != 7 || stats.TotalFiles < 1 {
+		t.Errorf("unexpected file counts %v, total %d", stats.LevelFiles, stats.TotalFiles)
+	}
+	levels, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if len(levels) == 0 || !reflect.DeepEqual(stats.Levels, levels) {
+		t.Errorf("levels = %+v, expected those of Stats %+v", stats.Levels, levels)
+	}
+	files := 0
+	for _, l := range stats.Levels {
+		files += l.Files
+	}
+	if files != stats.TotalFiles {
+		t.Errorf("levels hold %d files, expected %d", files, stats.TotalFiles)
+	}
+	if stats.ApproximateSize < 10000*100 {
+		t.Errorf("approximate size %d is too small", stats.ApproximateSize)
+	}
+	if stats.Snapshots != 1 {
+		t.Errorf("expected 1 snapshot, got %d", stats.Snapshots)
+	}
+}
+
+func TestAnalyzePrefixes(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("usr:%d", i)), []byte("12345"))
+	}
+	for i := 0; i < 3; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("grp:%d", i)), []byte("12"))
+	}
+	db.Put(nil, []byte("ab"), []byte("xyz"))
+
+	stats, err := db.AnalyzePrefixes(nil, 4)
+	if err != nil {
+		t.Fatalf("AnalyzePrefixes failed: %v", err)
+	}
+	want := map[string]PrefixStat{
+		"usr:": {10, 50},
+		"grp:": {3, 6},
+		"ab":   {1, 3},
+	}
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, stats)
+	}
+
+	for _, depth := range []int{0, -1} {
+		if _, err := db.AnalyzePrefixes(nil, depth); err == nil {
+			t.Errorf("AnalyzePrefixes with depth %d succeeded", depth)
+		}
+	}
+}
+
+func TestReportLeaks(t *testing.T) {
+	EnableLeakTracking(true)
+	defer EnableLeakTracking(false)
+
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	db.ReleaseSnapshot(db.GetSnapshot())
+	NewWriteOptions().Destroy()
+	leaked := NewReadOptions()
+	db.Close()
+
+	var report bytes.Buffer
+	if n := ReportLeaks(&report); n != 1 {
+		t.Errorf("expected 1 leak, got %d:\n%s", n, report.String())
+	}
+	if !strings.Contains(report.String(), "ReadOptions leaked") ||
+		!strings.Contains(report.String(), "TestReportLeaks") {
+		t.Errorf("report doesn't show the leaked ReadOptions:\n%s", report.String())
+	}
+
+	leaked.Destroy()
+	if n := ReportLeaks(&report); n != 0 {
+		t.Errorf("expected no leak after Destroy, got %d", n)
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("gone"), []byte("0"))
+
+	wb1, wb2, wb3 := NewWriteBatch(), NewWriteBatch(), NewWriteBatch()
+	defer wb1.Destroy()
+	defer wb2.Destroy()
+	defer wb3.Destroy()
+	wb1.Put([]byte("a"), []byte("1"))
+	wb1.Put([]byte("b"), []byte("1"))
+	wb1.Put([]byte("c"), []byte("1"))
+	wb2.Put([]byte("b"), []byte("2"))
+	wb2.Delete([]byte("c"))
+	wb2.Delete([]byte("gone"))
+	wb3.Put([]byte("c"), []byte("3"))
+	wb3.Put([]byte("d"), nil)
+
+	if err := db.WriteAll(nil, wb1, wb2, wb3); err != nil {
+		t.Fatalf("WriteAll failed: %v", err)
+	}
+	CheckGet(t, "WriteAll", db, nil, []byte("a"), []byte("1"))
+	CheckGet(t, "WriteAll", db, nil, []byte("b"), []byte("2"))
+	CheckGet(t, "WriteAll", db, nil, []byte("c"), []byte("3"))
+	CheckGet(t, "WriteAll", db, nil, []byte("d"), []byte{})
+	CheckGet(t, "WriteAll", db, nil, []byte("gone"), nil)
+
+	// the input batches are left alone
+	db.Delete(nil, []byte("b"))
+	if err := db.Write(nil, wb2); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	CheckGet(t, "input batch reused", db, nil, []byte("b"), []byte("2"))
+
+	// a batch appended to itself is doubled
+	wb1.Append(wb1)
+	wb1.Put([]byte("e"), []byte("1"))
+	db.Write(nil, wb1)
+	CheckGet(t, "self Append", db, nil, []byte("b"), []byte("1"))
+	CheckGet(t, "self Append", db, nil, []byte("e"), []byte("1"))
+}
+
+func TestSubscribePrefix(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("ns/a"), []byte("1"))
+	db.Put(nil, []byte("ns/b"), []byte("1"))
+	db.Put(nil, []byte("ns/c"), []byte("1"))
+	db.Put(nil, []byte("other"), []byte("1"))
+
+	events, stop := db.SubscribePrefix([]byte("ns/"), 200*time.Millisecond)
+	defer stop()
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	wb.Put([]byte("ns/a"), []byte("2"))
+	wb.Delete([]byte("ns/b"))
+	wb.Put([]byte("ns/d"), []byte("1"))
+	wb.Put([]byte("other"), []byte("2"))
+	db.Write(nil, wb)
+	// coalesced with the above
+	db.Put(nil, []byte("ns/a"), []byte("3"))
+	db.Put(nil, []byte("ns/e"), []byte("1"))
+	db.Delete(nil, []byte("ns/e"))
+
+	var changes []ChangeEvent
+	select {
+	case changes = <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no events received")
+	}
+	want := []ChangeEvent{
+		{KeyUpdated, []byte("ns/a"), []byte("3")},
+		{KeyDeleted, []byte("ns/b"), nil},
+		{KeyAdded, []byte("ns/d"), []byte("1")},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q", changes, want)
+	}
+
+	stop()
+	if _, ok := <-events; ok {
+		t.Error("events channel still open after stop")
+	}
+	stop()
+}
+
+func TestTunedOptions(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetParanoidChecks(true)
+	options.SetBlockRestartInterval(8)
+	options.SetBlockSize(16 << 10)
+	options.SetMaxOpenFiles(100)
+	options.SetWriteBufferSize(64 << 20)
+	options.SetCreateIfMissing(true)
+
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	const n = 5000
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%05d", i))
+		if err := db.Put(nil, key, []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%05d", i))
+		CheckGet(t, "tuned options", db, nil, key, []byte(strconv.Itoa(i)))
+	}
+}
+
+func TestOptionSetters(t *testing.T) {
+	setters := map[string]func(*Options){
+		"ErrorIfExists":        func(o *Options) { o.SetErrorIfExists(true) },
+		"ParanoidChecks":       func(o *Options) { o.SetParanoidChecks(true) },
+		"WriteBufferSize":      func(o *Options) { o.SetWriteBufferSize(1 << 20) },
+		"MaxOpenFiles":         func(o *Options) { o.SetMaxOpenFiles(64) },
+		"BlockSize":            func(o *Options) { o.SetBlockSize(1 << 10) },
+		"BlockRestartInterval": func(o *Options) { o.SetBlockRestartInterval(4) },
+		"Compression":          func(o *Options) { o.SetCompression(NoCompression) },
+		"Defaults":             func(o *Options) {},
+	}
+	for name, set := range setters {
+		dbname := tempDir(t)
+		options := NewOptions()
+		options.SetCreateIfMissing(true)
+		set(options)
+
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Errorf("%s: Open failed: %v", name, err)
+		} else {
+			if err := db.Put(nil, []byte("k"), []byte("v")); err != nil {
+				t.Errorf("%s: Put failed: %v", name, err)
+			}
+			db.Close()
+		}
+		options.Destroy()
+		deleteDBDirectory(t, dbname)
+	}
+}
+
+func TestErrorIfExists(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	db.Put(nil, []byte("key"), []byte("value"))
+	db.Close()
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetErrorIfExists(true)
+	if db, err := Open(dbname, options); err == nil {
+		db.Close()
+		t.Fatal("second Open succeeded with ErrorIfExists")
+	}
+
+	// the populated database is left alone
+	db, err := Open(dbname, nil)
+	if err != nil {
+		t.Fatalf("Unable to reopen db: %v", err)
+	}
+	defer db.Close()
+	CheckGet(t, "ErrorIfExists", db, nil, []byte("key"), []byte("value"))
+}
+
+func TestCompression(t *testing.T) {
+	// 4MB of highly compressible values
+	value := bytes.Repeat([]byte("abcd"), 512)
+	snappy := compressedSize(t, SnappyCompression, 2000, value)
+	none := compressedSize(t, NoCompression, 2000, value)
+	if snappy >= none {
+		t.Skipf("no gain from SnappyCompression (%d >= %d bytes), LevelDB built without Snappy?", snappy, none)
+	}
+	if snappy > none/2 {
+		t.Errorf("SnappyCompression size = %d, want at most half of NoCompression's %d", snappy, none)
+	}
+}
+
+func TestSetCompressionIgnoresUnknownType(t *testing.T) {
+	value := bytes.Repeat([]byte("a"), 1000)
+	unknown := compressedSize(t, CompressionType(42), 1000, value)
+	none := compressedSize(t, NoCompression, 1000, value)
+	snappy := compressedSize(t, SnappyCompression, 1000, value)
+	if unknown != snappy {
+		t.Errorf("size with an unknown CompressionType = %d, want the default's %d", unknown, snappy)
+	}
+	if snappy >= none {
+		t.Skipf("no gain from SnappyCompression (%d >= %d bytes), LevelDB built without Snappy?", snappy, none)
+	}
+}
+
+// compressedSize writes n entries with the given value to a new database
+// using compression c, and returns the approximate size of the database.
+func compressedSize(t *testing.T, c CompressionType, n int, value []byte) uint64 {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetCompression(c)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < n; i++ {
+		if err := db.Put(nil, []byte(fmt.Sprintf("key%05d", i)), value); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	db.CompactRange(nil, nil)
+	return db.GetApproximateSizes([]Range{{[]byte("key"), []byte("kez")}})[0]
+}
+
+func TestIteratorMethods(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+
+	db.Put(nil, []byte("a"), []byte("1"))
+	db.Put(nil, []byte("c"), []byte("2"))
+	db.Put(nil, []byte("e"), []byte("3"))
+
+	it := db.NewIterator(nil)
+	var keys, values []string
+	var first []byte
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if first == nil {
+			first = it.Key()
+		}
+		keys = append(keys, string(it.Key()))
+		values = append(values, string(it.Value()))
+	}
+	if got := strings.Join(keys, ","); got != "a,c,e" {
+		t.Errorf("forward keys = %s, want a,c,e", got)
+	}
+	if got := strings.Join(values, ","); got != "1,2,3" {
+		t.Errorf("forward values = %s, want 1,2,3", got)
+	}
+	if string(first) != "a" {
+		t.Errorf("retained key = %q, want \"a\"", first)
+	}
+
+	keys = keys[:0]
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		keys = append(keys, string(it.Key()))
+	}
+	if got := strings.Join(keys, ","); got != "e,c,a" {
+		t.Errorf("reverse keys = %s, want e,c,a", got)
+	}
+
+	it.Seek([]byte("b"))
+	CheckIter(t, it, []byte("c"), []byte("2"))
+	if err := it.SeekChecked([]byte("f")); err != nil {
+		t.Errorf("SeekChecked past the last key = %v, want nil", err)
+	}
+	if it.Valid() {
+		t.Errorf("iterator valid after seeking past the last key, at %q", it.Key())
+	}
+	if err := it.GetError(); err != nil {
+		t.Errorf("GetError = %v, want nil", err)
+	}
+	it.Close()
+
+	// errors reading the database show up in GetError
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+	}
+	db.CompactRange(nil, nil)
+	db.Close()
+	corruptTableFiles(t, dbname)
+
+	options := NewOptions()
+	defer options.Destroy()
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Unable to reopen db: %v", err)
+	}
+	defer db.Close()
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+
+	it = db.NewIterator(ro)
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+	}
+	if err := it.GetError(); !isCorruption(err) {
+		t.Errorf("GetError on a corrupted db = %v, want a corruption", err)
+	}
+
+	// SeekChecked reports them right away
+	it2 := db.NewIterator(ro)
+	defer it2.Close()
+	if err := it2.SeekChecked([]byte("key0000")); !isCorruption(err) {
+		t.Errorf("SeekChecked into a corrupted block = %v, want a corruption", err)
+	}
+}
+
+func TestComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	reverse := NewComparator("goleveldb.test.reverse", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	key := make([]byte, 4)
+	for _, i := range rand.Perm(100) {
+		binary.BigEndian.PutUint32(key, uint32(i))
+		db.Put(nil, key, []byte(strconv.Itoa(i)))
+	}
+	db.CompactRange(nil, nil)
+
+	it := db.NewIterator(nil)
+	want := 99
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if got := int(binary.BigEndian.Uint32(it.Key())); got != want {
+			t.Fatalf("key = %d, want %d", got, want)
+		}
+		if string(it.Value()) != strconv.Itoa(want) {
+			t.Errorf("value of %d = %q", want, it.Value())
+		}
+		want--
+	}
+	if want != -1 {
+		t.Errorf("iteration stopped before key %d", want)
+	}
+	it.Close()
+	if err := db.VerifyOrder(nil); err != nil {
+		t.Errorf("VerifyOrder failed: %v", err)
+	}
+	db.Close()
+
+	// the comparator name is checked when reopening
+	options = NewOptions()
+	defer options.Destroy()
+	if db, err := Open(dbname, options); err == nil {
+		db.Close()
+		t.Error("Open succeeded without the comparator the db was created with")
+	}
+}
+
+// uint64Comparator orders keys holding big-endian integers without leading
+// zero bytes, of varying length, by their numeric value.
+type uint64Comparator struct{}
+
+func (uint64Comparator) Name() string { return "goleveldb.test.uint64" }
+
+func (uint64Comparator) Compare(a, b []byte) int {
+	x, y := decodeUint64(a), decodeUint64(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return +1
+	}
+	return 0
+}
+
+func encodeUint64(n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	i := 0
+	for i < 7 && buf[i] == 0 {
+		i++
+	}
+	return buf[i:]
+}
+
+func decodeUint64(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+func TestComparatorFromImpl(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	cmp := NewComparatorFromImpl(uint64Comparator{})
+	defer cmp.Destroy()
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(cmp)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	nums := []uint64{1 << 40, 2, 256, 0, 70000, 255, 1 << 63}
+	for _, n := range nums {
+		db.Put(nil, encodeUint64(n), nil)
+	}
+	db.CompactRange(nil, nil)
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+
+	it := db.NewIterator(nil)
+	defer it.Close()
+	var got []uint64
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		got = append(got, decodeUint64(it.Key()))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(nums) {
+		t.Errorf("iteration order = %v, want %v", got, nums)
+	}
+
+	it.Seek(encodeUint64(300))
+	if !it.Valid() || decodeUint64(it.Key()) != 70000 {
+		t.Errorf("Seek(300) did not land on 70000")
+	}
+}
+
+func TestIteratorNextPrev(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Put(nil, []byte{'a' + byte(i)}, []byte{'0' + byte(i)})
+	}
+
+	it := db.NewIterator(nil)
+	defer it.Close()
+
+	it.Seek([]byte("e"))
+	for _, step := range []struct {
+		next bool
+		want string
+	}{
+		{true, "f"}, {false, "e"}, {false, "d"}, {true, "e"},
+		{true, "f"}, {true, "g"}, {false, "f"}, {true, "g"},
+	} {
+		if step.next {
+			it.Next()
+		} else {
+			it.Prev()
+		}
+		CheckIter(t, it, []byte(step.want), []byte{'0' + step.want[0] - 'a'})
+	}
+
+	it.SeekToFirst()
+	it.Prev()
+	if it.Valid() {
+		t.Errorf("iterator valid after Prev at the first key, at %q", it.Key())
+	}
+	it.SeekToLast()
+	it.Next()
+	if it.Valid() {
+		t.Errorf("iterator valid after Next at the last key, at %q", it.Key())
+	}
+}
+
+// firstByteFilter is a filter policy recording the first byte of the keys.
+type firstByteFilter struct {
+	rejected int64
+}
+
+func (f *firstByteFilter) Name() string { return "goleveldb.test.firstbyte" }
+
+func (f *firstByteFilter) CreateFilter(keys [][]byte) []byte {
+	filter := make([]byte, 32)
+	for _, key := range keys {
+		if len(key) > 0 {
+			filter[key[0]/8] |= 1 << (key[0] % 8)
+		}
+	}
+	return filter
+}
+
+func (f *firstByteFilter) KeyMayMatch(key, filter []byte) bool {
+	if len(key) == 0 || len(filter) != 32 {
+		return true
+	}
+	if filter[key[0]/8]&(1<<(key[0]%8)) != 0 {
+		return true
+	}
+	atomic.AddInt64(&f.rejected, 1)
+	return false
+}
+
+func TestFilterPolicy(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	impl := &firstByteFilter{}
+	policy := NewFilterPolicy(impl)
+	defer policy.Destroy()
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetFilterPolicy(policy)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("a%04d", i)), []byte("value"))
+		db.Put(nil, []byte(fmt.Sprintf("c%04d", i)), []byte("value"))
+	}
+	db.CompactRange(nil, nil)
+
+	for i := 0; i < 1000; i += 100 {
+		CheckGet(t, "FilterPolicy", db, nil, []byte(fmt.Sprintf("a%04d", i)), []byte("value"))
+		CheckGet(t, "FilterPolicy", db, nil, []byte(fmt.Sprintf("c%04d", i)), []byte("value"))
+	}
+	if n := atomic.LoadInt64(&impl.rejected); n != 0 {
+		t.Errorf("filter rejected %d existing keys", n)
+	}
+
+	// a miss with a first byte not in the table is answered by the filter,
+	// without reading a data block
+	CheckGet(t, "FilterPolicy", db, nil, []byte("a5000"), nil)
+	if n := atomic.LoadInt64(&impl.rejected); n != 0 {
+		t.Errorf("filter rejected %d keys sharing the first byte of the table", n)
+	}
+	CheckGet(t, "FilterPolicy", db, nil, []byte("b0500"), nil)
+	if n := atomic.LoadInt64(&impl.rejected); n == 0 {
+		t.Error("filter was not consulted on a miss")
+	}
+}
+
+func TestFilterPolicyFunc(t *testing.T) {
+	var consulted, rejected int64
+	// prefix filters hold the distinct 2-byte prefixes of the keys
+	prefixFilter := NewFilterPolicyFunc("goleveldb.test.prefix2",
+		func(keys [][]byte) []byte {
+			var filter []byte
+			for _, key := range keys {
+				if len(key) >= 2 && !bytes.Contains(filter, key[:2]) {
+					filter = append(filter, key[:2]...)
+				}
+			}
+			return filter
+		},
+		func(key, filter []byte) bool {
+			atomic.AddInt64(&consulted, 1)
+			if len(key) < 2 {
+				return true
+			}
+			for i := 0; i+2 <= len(filter); i += 2 {
+				if bytes.Equal(filter[i:i+2], key[:2]) {
+					return true
+				}
+			}
+			atomic.AddInt64(&rejected, 1)
+			return false
+		})
+	defer prefixFilter.Destroy()
+	matchAll := NewFilterPolicyFunc("goleveldb.test.all",
+		func(keys [][]byte) []byte { return nil },
+		func(key, filter []byte) bool { return true })
+	defer matchAll.Destroy()
+
+	for _, policy := range []*FilterPolicy{matchAll, prefixFilter} {
+		dbname := tempDir(t)
+		defer deleteDBDirectory(t, dbname)
+		options := NewOptions()
+		defer options.Destroy()
+		options.SetCreateIfMissing(true)
+		options.SetFilterPolicy(policy)
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+
+		for i := 0; i < 1000; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("aa%04d", i)), []byte("value"))
+			db.Put(nil, []byte(fmt.Sprintf("ac%04d", i)), []byte("value"))
+		}
+		db.CompactRange(nil, nil)
+		for i := 0; i < 1000; i += 100 {
+			CheckGet(t, "FilterPolicyFunc", db, nil, []byte(fmt.Sprintf("aa%04d", i)), []byte("value"))
+			CheckGet(t, "FilterPolicyFunc", db, nil, []byte(fmt.Sprintf("ac%04d", i)), []byte("value"))
+		}
+		CheckGet(t, "FilterPolicyFunc", db, nil, []byte("ab0500"), nil)
+	}
+	if atomic.LoadInt64(&consulted) == 0 || atomic.LoadInt64(&rejected) != 1 {
+		t.Errorf("prefix filter consulted %d times, rejected %d keys, want 1 rejection",
+			consulted, rejected)
+	}
+}
+
+func TestWriteBatchCount(t *testing.T) {
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	if wb.Count() != 0 || wb.ApproximateSize() != 0 {
+		t.Errorf("new batch: Count = %d, ApproximateSize = %d", wb.Count(), wb.ApproximateSize())
+	}
+
+	const n = 100
+	size := 0
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		value := bytes.Repeat([]byte("v"), i*3)
+		wb.Put(key, value)
+		wb.Delete(key)
+		size += len(key)*2 + len(value)
+	}
+	if wb.Count() != 2*n {
+		t.Errorf("Count = %d, want %d", wb.Count(), 2*n)
+	}
+	if got := wb.ApproximateSize(); got < size || got > size+6*n {
+		t.Errorf("ApproximateSize = %d, want about %d", got, size)
+	}
+
+	wb.Clear()
+	if wb.Count() != 0 || wb.ApproximateSize() != 0 {
+		t.Errorf("cleared batch: Count = %d, ApproximateSize = %d", wb.Count(), wb.ApproximateSize())
+	}
+}
+
+func TestWriteBatchCountTransitions(t *testing.T) {
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+
+	for i := 0; i < 5; i++ {
+		wb.Put([]byte{byte(i)}, []byte("v"))
+	}
+	if wb.Count() != 5 {
+		t.Errorf("Count after 5 puts = %d, want 5", wb.Count())
+	}
+	for i := 0; i < 3; i++ {
+		wb.Delete([]byte{byte(i)})
+	}
+	if wb.Count() != 8 {
+		t.Errorf("Count after 3 deletes = %d, want 8", wb.Count())
+	}
+	wb.Clear()
+	if wb.Count() != 0 {
+		t.Errorf("Count after Clear = %d, want 0", wb.Count())
+	}
+	wb.Put([]byte("a"), nil)
+	wb.Put([]byte("b"), nil)
+	if wb.Count() != 2 {
+		t.Errorf("Count after 2 more puts = %d, want 2", wb.Count())
+	}
+
+	// appended updates are counted too
+	other := NewWriteBatch()
+	defer other.Destroy()
+	other.Append(wb)
+	other.Append(wb)
+	if other.Count() != 4 {
+		t.Errorf("Count after appending twice = %d, want 4", other.Count())
+	}
+}
+
+func TestWriteBatchIterate(t *testing.T) {
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	wb.Put([]byte("a"), []byte("1"))
+	wb.Delete([]byte("b"))
+	wb.Put([]byte("c"), nil)
+	wb.Put([]byte("a"), []byte("2"))
+	wb.Delete([]byte("a"))
+
+	var ops []string
+	var kept []KV
+	wb.Iterate(func(key, value []byte) {
+		ops = append(ops, fmt.Sprintf("put %s=%s", key, value))
+		kept = append(kept, KV{key, value})
+	}, func(key []byte) {
+		ops = append(ops, fmt.Sprintf("delete %s", key))
+		kept = append(kept, KV{key, nil})
+	})
+	want := "put a=1,delete b,put c=,put a=2,delete a"
+	if got := strings.Join(ops, ","); got != want {
+		t.Errorf("Iterate replayed %s, want %s", got, want)
+	}
+
+	// the slices passed are copies, unaffected by later changes of the batch
+	wb.Clear()
+	wb.Put([]byte("x"), []byte("9"))
+	wb.Put([]byte("y"), []byte("9"))
+	var got []string
+	for _, kv := range kept {
+		got = append(got, string(kv.Key)+"="+string(kv.Value))
+	}
+	if s := strings.Join(got, ","); s != "a=1,b=,c=,a=2,a=" {
+		t.Errorf("retained updates = %s, want a=1,b=,c=,a=2,a=", s)
+	}
+
+	wb.Clear()
+	wb.Iterate(func(key, value []byte) {
+		t.Errorf("put %q replayed from a cleared batch", key)
+	}, func(key []byte) {
+		t.Errorf("delete %q replayed from a cleared batch", key)
+	})
+}
+
+func TestGetInto(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("key"), []byte("0123456789"))
+	db.Put(nil, []byte("empty"), nil)
+
+	buf := make([]byte, 16)
+	n, err := db.GetInto(nil, []byte("key"), buf)
+	if err != nil || n != 10 || string(buf[:n]) != "0123456789" {
+		t.Errorf("GetInto = %d, %v, %q", n, err, buf[:n])
+	}
+
+	buf = make([]byte, 4)
+	n, err = db.GetInto(nil, []byte("key"), buf)
+	if err != io.ErrShortBuffer || n != 10 {
+		t.Errorf("GetInto with a short buffer = %d, %v, want 10, io.ErrShortBuffer", n, err)
+	}
+	if !bytes.Equal(buf, make([]byte, 4)) {
+		t.Errorf("GetInto with a short buffer wrote %q", buf)
+	}
+	buf = make([]byte, n)
+	n, err = db.GetInto(nil, []byte("key"), buf)
+	if err != nil || n != 10 || string(buf) != "0123456789" {
+		t.Errorf("GetInto with an exact buffer = %d, %v, %q", n, err, buf)
+	}
+
+	n, err = db.GetInto(nil, []byte("empty"), buf)
+	if err != nil || n != 0 {
+		t.Errorf("GetInto on an empty value = %d, %v", n, err)
+	}
+	n, err = db.GetInto(nil, []byte("empty"), nil)
+	if err != nil || n != 0 {
+		t.Errorf("GetInto on an empty value with no buffer = %d, %v", n, err)
+	}
+	if _, err := db.GetInto(nil, []byte("missing"), buf); err != ErrNotFound {
+		t.Errorf("GetInto on a missing key: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPutMultiDeleteMulti(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	kvs := make(map[string][]byte)
+	for i := 0; i < 100; i++ {
+		kvs[fmt.Sprintf("key%03d", i)] = []byte(strconv.Itoa(i))
+	}
+	if err := db.PutMulti(nil, kvs); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+	for key, value := range kvs {
+		CheckGet(t, "PutMulti", db, nil, []byte(key), value)
+	}
+
+	if err := db.PutMulti(nil, nil); err != nil {
+		t.Errorf("PutMulti with no entries failed: %v", err)
+	}
+	if err := db.DeleteMulti(nil, nil); err != nil {
+		t.Errorf("DeleteMulti with no keys failed: %v", err)
+	}
+
+	if err := db.DeleteMulti(nil, [][]byte{[]byte("key000"), []byte("key050"), []byte("missing")}); err != nil {
+		t.Fatalf("DeleteMulti failed: %v", err)
+	}
+	CheckGet(t, "DeleteMulti", db, nil, []byte("key000"), nil)
+	CheckGet(t, "DeleteMulti", db, nil, []byte("key050"), nil)
+	CheckGet(t, "DeleteMulti", db, nil, []byte("key001"), []byte("1"))
+}
+
+func TestMultiGet(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("a"), []byte("1"))
+	db.Put(nil, []byte("c"), []byte("3"))
+	db.Put(nil, []byte("e"), nil)
+
+	keys := [][]byte{[]byte("e"), []byte("b"), []byte("a"), []byte("c"), []byte("d"), []byte("a")}
+	values, errs := db.MultiGet(nil, keys)
+	if len(values) != len(keys) || len(errs) != len(keys) {
+		t.Fatalf("MultiGet returned %d values and %d errors for %d keys", len(values), len(errs), len(keys))
+	}
+	want := []string{"", "", "1", "3", "", "1"}
+	missing := []bool{false, true, false, false, true, false}
+	for i, key := range keys {
+		if missing[i] {
+			if values[i] != nil || errs[i] != ErrNotFound {
+				t.Errorf("MultiGet %q = %q, %v, want nil, ErrNotFound", key, values[i], errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || values[i] == nil || string(values[i]) != want[i] {
+			t.Errorf("MultiGet %q = %q, %v, want %q", key, values[i], errs[i], want[i])
+		}
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 1000; i += 2 {
+		db.Put(nil, []byte(strconv.Itoa(i)), []byte("value"+strconv.Itoa(i)))
+	}
+	keys := make([][]byte, 1000)
+	for i, n := range rand.New(rand.NewSource(1)).Perm(1000) {
+		keys[i] = []byte(strconv.Itoa(n))
+	}
+
+	defer func(workers int) { GetMultiWorkers = workers }(GetMultiWorkers)
+	for _, workers := range []int{0, 3, 16} {
+		GetMultiWorkers = workers
+		values, errs := db.GetMulti(nil, keys)
+		if len(values) != len(keys) || len(errs) != len(keys) {
+			t.Fatalf("GetMulti returned %d values and %d errors for %d keys", len(values), len(errs), len(keys))
+		}
+		for i, key := range keys {
+			n, _ := strconv.Atoi(string(key))
+			if n%2 == 1 {
+				if values[i] != nil || errs[i] != ErrNotFound {
+					t.Errorf("%d workers: GetMulti %q = %q, %v, want nil, ErrNotFound", workers, key, values[i], errs[i])
+				}
+			} else if errs[i] != nil || string(values[i]) != "value"+string(key) {
+				t.Errorf("%d workers: GetMulti %q = %q, %v", workers, key, values[i], errs[i])
+			}
+		}
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	var db io.Closer = openTestDB(t, dbname)
+
+	if err := db.Close(); err != nil {
+		t.Errorf("first Close failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	db.Put(nil, []byte("key"), []byte("value"))
+	snap := db.GetSnapshot()
+	db.Close()
+
+	if _, err := db.Get(nil, []byte("key")); err != ErrClosed {
+		t.Errorf("Get after Close = %v, want ErrClosed", err)
+	}
+	if has, err := db.Has(nil, []byte("key")); has || err != ErrClosed {
+		t.Errorf("Has after Close = %v, %v, want ErrClosed", has, err)
+	}
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != ErrClosed {
+		t.Errorf("Put after Close = %v, want ErrClosed", err)
+	}
+	if err := db.Delete(nil, []byte("key")); err != ErrClosed {
+		t.Errorf("Delete after Close = %v, want ErrClosed", err)
+	}
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	wb.Put([]byte("key"), []byte("value"))
+	if err := db.Write(nil, wb); err != ErrClosed {
+		t.Errorf("Write after Close = %v, want ErrClosed", err)
+	}
+	if _, errs := db.MultiGet(nil, [][]byte{[]byte("key")}); errs[0] != ErrClosed {
+		t.Errorf("MultiGet after Close = %v, want ErrClosed", errs[0])
+	}
+	if _, err := db.CountRange(nil, Range{}); err != ErrClosed {
+		t.Errorf("CountRange after Close = %v, want ErrClosed", err)
+	}
+	if _, err := db.NumFilesAtLevel(0); err != ErrClosed {
+		t.Errorf("NumFilesAtLevel after Close = %v, want ErrClosed", err)
+	}
+
+	it := db.NewIterator(nil)
+	it.SeekToFirst()
+	if it.Valid() || it.Error() != ErrClosed {
+		t.Errorf("iterator after Close: Valid = %v, Error = %v, want ErrClosed", it.Valid(), it.Error())
+	}
+	it.Close()
+	it.Close()
+
+	if snap.snap != nil {
+		t.Errorf("Close left a snapshot live")
+	}
+	// no-ops
+	db.ReleaseSnapshot(snap)
+	db.ReleaseSnapshot(db.GetSnapshot())
+	if n := db.SnapshotCount(); n != 0 {
+		t.Errorf("SnapshotCount after Close = %d", n)
+	}
+	db.CompactRange(nil, nil)
+	if value := db.GetProperty("leveldb.stats"); value != "" {
+		t.Errorf("GetProperty after Close = %q", value)
+	}
+	if sizes := db.GetApproximateSizes([]Range{{[]byte("a"), []byte("z")}}); len(sizes) != 1 || sizes[0] != 0 {
+		t.Errorf("GetApproximateSizes after Close = %v", sizes)
+	}
+}
+
+func TestLevelDBError(t *testing.T) {
+	for _, test := range []struct {
+		msg  string
+		kind ErrorKind
+		want error
+	}{
+		{"Corruption: bad block contents", KindCorruption, ErrCorruption},
+		{"IO error: /db/LOCK: No such file or directory", KindIOError, ErrIOError},
+		{"Invalid argument: /db: exists (error_if_exists is true)", KindInvalidArgument, ErrInvalidArgument},
+		{"NotFound: /db/000005.ldb", KindNotFound, ErrNotFound},
+		{"Not implemented: feature", KindUnknown, nil},
+		{"IO error", KindUnknown, nil},
+	} {
+		err := newLevelDBError(test.msg)
+		if err.Error() != test.msg {
+			t.Errorf("message = %q, want %q", err.Error(), test.msg)
+		}
+		var lerr *LevelDBError
+		if !errors.As(err, &lerr) {
+			t.Errorf("%q is not a *LevelDBError", test.msg)
+		} else if lerr.Kind != test.kind {
+			t.Errorf("kind of %q = %v, want %v", test.msg, lerr.Kind, test.kind)
+		}
+		for _, sentinel := range []error{ErrNotFound, ErrCorruption, ErrIOError, ErrInvalidArgument} {
+			if got := errors.Is(err, sentinel); got != (sentinel == test.want) {
+				t.Errorf("errors.Is(%q, %v) = %v", test.msg, sentinel, got)
+			}
+		}
+	}
+
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	openTestDB(t, dbname).Close()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetErrorIfExists(true)
+	if _, err := Open(dbname, options); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Open of an existing db with ErrorIfExists = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := Open(filepath.Join(dbname, "missing"), nil); err == nil {
+		t.Errorf("Open of a missing db succeeded")
+	} else if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Open of a missing db = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestFinalizers(t *testing.T) {
+	// waitFinalized collects garbage until no more objects get finalized.
+	waitFinalized := func() int64 {
+		n := atomic.LoadInt64(&finalized)
+		for i := 0; i < 100; i++ {
+			runtime.GC()
+			time.Sleep(10 * time.Millisecond)
+			prev := n
+			if n = atomic.LoadInt64(&finalized); n == prev && i > 0 {
+				break
+			}
+		}
+		return n
+	}
+	// let the garbage of the previous tests be finalized first
+	base := waitFinalized()
+
+	func() {
+		// forgotten
+		NewOptions().SetCreateIfMissing(true)
+		NewReadOptions()
+		NewWriteOptions()
+		NewWriteBatch().Put([]byte("key"), []byte("value"))
+		NewLRUCache(1 << 10)
+		NewBloomFilterPolicy(10)
+		NewFilterPolicy(&firstByteFilter{})
+
+		// destroyed, the finalizer must not free them again
+		NewOptions().Destroy()
+		NewReadOptions().Destroy()
+		NewWriteOptions().Destroy()
+		NewWriteBatch().Destroy()
+		NewLRUCache(1 << 10).Destroy()
+		NewBloomFilterPolicy(10).Destroy()
+		NewFilterPolicy(&firstByteFilter{}).Destroy()
+	}()
+
+	if got := waitFinalized() - base; got != 7 {
+		t.Errorf("%d objects finalized, want 7", got)
+	}
+
+	// Options going out of scope undestroyed while the DB opened with them
+	// is still in use
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	base = waitFinalized()
+	db := func() *DB {
+		options := NewOptions()
+		options.SetCreateIfMissing(true)
+		options.SetCache(NewLRUCache(1 << 20))
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}()
+	if got := waitFinalized() - base; got != 1 {
+		t.Errorf("%d objects finalized, want the Options only", got)
+	}
+	db.Put(nil, []byte("key"), []byte("value"))
+	CheckGet(t, "finalized Options", db, nil, []byte("key"), []byte("value"))
+	db.Close()
+}
+
+func TestCacheAndFilterPolicyOutliveReferences(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	db := func() *DB {
+		options := NewOptions()
+		defer options.Destroy()
+		options.SetCreateIfMissing(true)
+		options.SetCache(NewLRUCache(1 << 20))
+		options.SetFilterPolicy(NewBloomFilterPolicy(10))
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return db
+	}()
+	defer db.Close()
+
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		if err := db.Put(nil, []byte(fmt.Sprintf("key%03d", i)), []byte("value")); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	db.CompactRange(nil, nil)
+	runtime.GC()
+	CheckGet(t, "after GC", db, nil, []byte("key042"), []byte("value"))
+	CheckGet(t, "after GC", db, nil, []byte("missing"), nil)
+}
+
+func TestSharedCache(t *testing.T) {
+	cache := NewLRUCache(1 << 20)
+	defer cache.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetCache(cache)
+
+	value := bytes.Repeat([]byte("v"), 1000)
+	var usages []uint64
+	for i := 0; i < 2; i++ {
+		dbname := tempDir(t)
+		defer deleteDBDirectory(t, dbname)
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer db.Close()
+
+		for j := 0; j < 100; j++ {
+			db.Put(nil, []byte(fmt.Sprintf("key%03d", j)), value)
+		}
+		db.CompactRange(nil, nil)
+		for j := 0; j < 100; j++ {
+			CheckGet(t, "shared cache", db, nil, []byte(fmt.Sprintf("key%03d", j)), value)
+		}
+		usages = append(usages, cache.Usage())
+	}
+
+	if usages[1] == 0 {
+		t.Skip("leveldb_cache_get_usage is not in the linked library")
+	}
+	if usages[1] <= usages[0] {
+		t.Errorf("Usage after reading from the second DB = %d, %d after the first", usages[1], usages[0])
+	}
+}
+
+func TestCacheCapacity(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	cache := NewLRUCache(64 << 10)
+	defer cache.Destroy()
+	if cache.Capacity() != 64<<10 {
+		t.Errorf("Capacity = %d, want %d", cache.Capacity(), 64<<10)
+	}
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetCache(cache)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	// read 10 times the capacity of the cache through it
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := 0; i < 640; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), value)
+	}
+	db.CompactRange(nil, nil)
+	var early uint64
+	for i := 0; i < 640; i++ {
+		CheckGet(t, "small cache", db, nil, []byte(fmt.Sprintf("key%04d", i)), value)
+		if i == 10 {
+			early = cache.Usage()
+		}
+	}
+	if cache.Capacity() != 64<<10 {
+		t.Errorf("Capacity after use = %d, want %d", cache.Capacity(), 64<<10)
+	}
+
+	usage := cache.Usage()
+	if usage == 0 {
+		t.Skip("leveldb_cache_get_usage is not in the linked library")
+	}
+	if usage <= early {
+		t.Errorf("Usage = %d after filling the cache, %d early on", usage, early)
+	}
+	// the LRU cache is sharded, each shard may go a block over its capacity
+	if usage < 32<<10 || usage > 64<<10+16*4<<10 {
+		t.Errorf("Usage = %d, want near the capacity %d", usage, 64<<10)
+	}
+}
+
+func TestCompactRangeContext(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := db.CompactRangeContext(ctx, nil, nil); err != context.Canceled {
+		t.Errorf("CompactRangeContext with a canceled context = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("CompactRangeContext with a canceled context took %v", d)
+	}
+
+	if err := db.CompactRangeContext(context.Background(), nil, nil); err != nil {
+		t.Errorf("CompactRangeContext failed: %v", err)
+	}
+	if n := len(tableFiles(dbname)); n == 0 {
+		t.Error("no table written by the compaction")
+	}
+	CheckGet(t, "CompactRangeContext", db, nil, []byte("key0500"), []byte("value"))
+}
+
+func TestContextOperations(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	ctx := context.Background()
+	wo := NewWriteOptions()
+	defer wo.Destroy()
+	wo.SetSync(true)
+	if err := db.PutContext(ctx, wo, []byte("a"), []byte("1")); err != nil {
+		t.Errorf("PutContext failed: %v", err)
+	}
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	wb.Put([]byte("b"), []byte("2"))
+	wb.Delete([]byte("a"))
+	if err := db.WriteContext(ctx, nil, wb); err != nil {
+		t.Errorf("WriteContext failed: %v", err)
+	}
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	if value, err := db.GetContext(ctx, ro, []byte("b")); err != nil || string(value) != "2" {
+		t.Errorf("GetContext = %q, %v, want \"2\"", value, err)
+	}
+	if _, err := db.GetContext(ctx, nil, []byte("a")); err != ErrNotFound {
+		t.Errorf("GetContext on a deleted key: expected ErrNotFound, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.GetContext(canceled, nil, []byte("b")); err != context.Canceled {
+		t.Errorf("GetContext with a canceled context = %v", err)
+	}
+	if err := db.PutContext(canceled, nil, []byte("c"), []byte("3")); err != context.Canceled {
+		t.Errorf("PutContext with a canceled context = %v", err)
+	}
+	if err := db.WriteContext(canceled, nil, wb); err != context.Canceled {
+		t.Errorf("WriteContext with a canceled context = %v", err)
+	}
+	CheckGet(t, "canceled PutContext", db, nil, []byte("c"), nil)
+}
+
+func TestGetContextSnapshot(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+	db.Put(nil, []byte("key"), []byte("value"))
+
+	// A snapshot released during a background read is freed once the read
+	// is over.
+	snap := db.GetSnapshot()
+	if !db.holdSnapshot(snap) {
+		t.Fatalf("holdSnapshot failed on a live snapshot")
+	}
+	db.ReleaseSnapshot(snap)
+	if snap.snap == nil {
+		t.Errorf("snapshot freed while held")
+	}
+	if db.holdSnapshot(snap) {
+		t.Errorf("holdSnapshot succeeded on a released snapshot")
+	}
+	db.unholdSnapshot(snap)
+	if snap.snap != nil {
+		t.Errorf("released snapshot not freed by the last unholdSnapshot")
+	}
+
+	// Reads canceled right away, with the snapshot released as soon as
+	// they return.
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	for i := 0; i < 100; i++ {
+		snap := db.GetSnapshot()
+		ro.SetSnapshot(snap)
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		value, err := db.GetContext(ctx, ro, []byte("key"))
+		if err == nil && string(value) != "value" {
+			t.Errorf("GetContext = %q", value)
+		} else if err != nil && err != context.Canceled {
+			t.Errorf("GetContext failed: %v", err)
+		}
+		db.ReleaseSnapshot(snap)
+	}
+	if _, err := db.GetContext(context.Background(), ro, []byte("key")); err != ErrSnapshotReleased {
+		t.Errorf("GetContext with a released snapshot = %v, want ErrSnapshotReleased", err)
+	}
+}
+
+func TestNewRangeIterator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		db.Put(nil, []byte(k), []byte(k))
+	}
+
+	scan := func(r Range) (forward, reverse string) {
+		it := db.NewRangeIterator(nil, r)
+		defer it.Close()
+		var keys []string
+		for ; it.Valid(); it.Next() {
+			keys = append(keys, string(it.Key()))
+		}
+		forward = strings.Join(keys, ",")
+		keys = keys[:0]
+		for it.SeekToLast(); it.Valid(); it.Prev() {
+			keys = append(keys, string(it.Key()))
+		}
+		return forward, strings.Join(keys, ",")
+	}
+	for _, test := range []struct {
+		r                Range
+		forward, reverse string
+	}{
+		{Range{nil, nil}, "a,b,c,d,e", "e,d,c,b,a"},
+		{Range{nil, []byte("c")}, "a,b", "b,a"},
+		{Range{[]byte("bb"), nil}, "c,d,e", "e,d,c"},
+		{Range{[]byte("b"), []byte("d")}, "b,c", "c,b"},
+		{Range{[]byte("b"), []byte("b")}, "", ""},
+	} {
+		forward, reverse := scan(test.r)
+		if forward != test.forward || reverse != test.reverse {
+			t.Errorf("range [%q, %q) = %s / %s, want %s / %s",
+				test.r.Start, test.r.Limit, forward, reverse, test.forward, test.reverse)
+		}
+	}
+}
+
+func TestNewRangeIteratorComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	cmp := NewComparatorFromImpl(uint64Comparator{})
+	defer cmp.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(cmp)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, n := range []uint64{2, 255, 256, 1000, 70000} {
+		db.Put(nil, encodeUint64(n), nil)
+	}
+	// bytewise, 256 = "\x01\x00" would sort before 2
+	it := db.NewRangeIterator(nil, Range{encodeUint64(100), encodeUint64(1000)})
+	defer it.Close()
+	var got []uint64
+	for ; it.Valid(); it.Next() {
+		got = append(got, decodeUint64(it.Key()))
+	}
+	if fmt.Sprint(got) != "[255 256]" {
+		t.Errorf("range [100, 1000) = %v, want [255 256]", got)
+	}
+}
+
+func TestSnapshotSequenceNumber(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	var snaps []*Snapshot
+	seen := make(map[uint64]bool)
+	var last uint64
+	for i := 0; i < 5; i++ {
+		snap := db.GetSnapshot()
+		seq := snap.SequenceNumber()
+		if seen[seq] || seq <= last {
+			t.Errorf("snapshot %d: SequenceNumber %d after %d", i, seq, last)
+		}
+		seen[seq], last = true, seq
+		snaps = append(snaps, snap)
+	}
+	if n := db.SnapshotCount(); n != 5 {
+		t.Errorf("SnapshotCount = %d, want 5", n)
+	}
+	for i, snap := range snaps {
+		db.ReleaseSnapshot(snap)
+		if n := db.SnapshotCount(); n != 4-i {
+			t.Errorf("SnapshotCount after %d releases = %d, want %d", i+1, n, 4-i)
+		}
+	}
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	if seq := snap.SequenceNumber(); seq <= last {
+		t.Errorf("SequenceNumber %d reused after releases", seq)
+	}
+}
+
+func TestNewPrefixIterator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for _, k := range []string{"user", "user:1", "user:2", "user:3", "userx", "\xff", "\xff\xff", "\xff\xffa"} {
+		db.Put(nil, []byte(k), nil)
+	}
+
+	scan := func(prefix string) (forward, reverse string) {
+		it := db.NewPrefixIterator(nil, []byte(prefix))
+		defer it.Close()
+		var keys []string
+		for ; it.Valid(); it.Next() {
+			keys = append(keys, strconv.Quote(string(it.Key())))
+		}
+		forward = strings.Join(keys, ",")
+		keys = keys[:0]
+		for it.SeekToLast(); it.Valid(); it.Prev() {
+			keys = append(keys, strconv.Quote(string(it.Key())))
+		}
+		return forward, strings.Join(keys, ",")
+	}
+	for _, test := range []struct {
+		prefix, forward, reverse string
+	}{
+		{"user:", `"user:1","user:2","user:3"`, `"user:3","user:2","user:1"`},
+		{"user:2", `"user:2"`, `"user:2"`},
+		{"users", ``, ``},
+		{"\xff\xff", `"\xff\xff","\xff\xffa"`, `"\xff\xffa","\xff\xff"`},
+	} {
+		forward, reverse := scan(test.prefix)
+		if forward != test.forward || reverse != test.reverse {
+			t.Errorf("prefix %q = %s / %s, want %s / %s",
+				test.prefix, forward, reverse, test.forward, test.reverse)
+		}
+	}
+}
+
+func TestNewReversePrefixIterator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for _, k := range []string{"ts", "ts:1", "ts:2", "ts:3", "ts:4", "tt", "\xff", "\xff\xff", "\xff\xffa"} {
+		db.Put(nil, []byte(k), nil)
+	}
+
+	for _, test := range []struct {
+		prefix string
+		want   string
+	}{
+		{"ts:", `"ts:4","ts:3","ts:2","ts:1"`},
+		{"ts:3", `"ts:3"`},
+		{"ts:5", ``},
+		{"\xff\xff", `"\xff\xffa","\xff\xff"`},
+		{"\xff\xffb", ``},
+	} {
+		it := db.NewReversePrefixIterator(nil, []byte(test.prefix))
+		var keys []string
+		for ; it.Valid(); it.Prev() {
+			keys = append(keys, strconv.Quote(string(it.Key())))
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("prefix %q: %v", test.prefix, err)
+		}
+		it.Close()
+		if got := strings.Join(keys, ","); got != test.want {
+			t.Errorf("prefix %q = %s, want %s", test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestPrefixIteratorComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	// "ts" sorts after the keys starting with it, "tt" before them.
+	reverse := NewComparator("goleveldb.test.reverse", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	for _, k := range []string{"ts", "ts:1", "ts:2", "ts:3", "tt"} {
+		db.Put(nil, []byte(k), nil)
+	}
+
+	scan := func(it *Iterator, step func()) string {
+		defer it.Close()
+		var keys []string
+		for ; it.Valid(); step() {
+			keys = append(keys, strconv.Quote(string(it.Key())))
+		}
+		return strings.Join(keys, ",")
+	}
+	for _, test := range []struct {
+		prefix, want string
+	}{
+		{"ts", `"ts:3","ts:2","ts:1","ts"`},
+		{"ts:", `"ts:3","ts:2","ts:1"`},
+		{"ts:4", ``},
+		{"", `"tt","ts:3","ts:2","ts:1","ts"`},
+	} {
+		it := db.NewPrefixIterator(nil, []byte(test.prefix))
+		if got := scan(it, it.Next); got != test.want {
+			t.Errorf("prefix %q = %s, want %s", test.prefix, got, test.want)
+		}
+		it = db.NewReversePrefixIterator(nil, []byte(test.prefix))
+		var want []string
+		if test.want != "" {
+			want = strings.Split(test.want, ",")
+		}
+		for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+			want[i], want[j] = want[j], want[i]
+		}
+		if got := scan(it, it.Prev); got != strings.Join(want, ",") {
+			t.Errorf("reverse prefix %q = %s, want %s", test.prefix, got, strings.Join(want, ","))
+		}
+	}
+}
+
+func TestIteratorNextBatch(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	// more than a cgo call copies
+	for i := 0; i < 3000; i++ {
+		value := []byte(strconv.Itoa(i))
+		if i == 500 {
+			// larger than a NextBatch buffer
+			value = bytes.Repeat([]byte("v"), 3*nextBatchBufferSize)
+		}
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), value)
+	}
+
+	naive := func(it *Iterator) (entries []KV) {
+		for ; it.Valid(); it.Next() {
+			entries = append(entries, KV{it.Key(), it.Value()})
+		}
+		return entries
+	}
+	batched := func(it *Iterator, max int) (entries []KV) {
+		for done := false; !done; {
+			var keys, values [][]byte
+			keys, values, done = it.NextBatch(max)
+			if len(keys) > max || len(keys) != len(values) {
+				t.Fatalf("NextBatch(%d) returned %d keys and %d values", max, len(keys), len(values))
+			}
+			for i := range keys {
+				if cap(values[i]) != len(values[i]) {
+					t.Fatalf("NextBatch(%d) returned a value of %d bytes sharing %d", max, len(values[i]), cap(values[i]))
+				}
+				entries = append(entries, KV{keys[i], values[i]})
+			}
+		}
+		return entries
+	}
+	same := func(a, b []KV) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !bytes.Equal(a[i].Key, b[i].Key) || !bytes.Equal(a[i].Value, b[i].Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, max := range []int{1, 7, 256, 5000, math.MaxInt} {
+		it := db.NewIterator(nil)
+		it.SeekToFirst()
+		want := naive(it)
+		it.SeekToFirst()
+		if got := batched(it, max); !same(got, want) {
+			t.Errorf("NextBatch(%d) returned %d entries differing from the %d of a scan", max, len(got), len(want))
+		}
+		if it.Valid() {
+			t.Errorf("iterator valid after NextBatch(%d) returned done", max)
+		}
+		it.Close()
+
+		r := Range{[]byte("key0100"), []byte("key0200")}
+		it = db.NewRangeIterator(nil, r)
+		want = naive(it)
+		it.SeekToFirst()
+		if got := batched(it, max); !same(got, want) || len(got) != 100 {
+			t.Errorf("NextBatch(%d) in a range returned %d entries, want 100", max, len(got))
+		}
+		it.Close()
+	}
+}
+
+func TestOptionsPool(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if err := db.Put(nil, []byte("key"), []byte("v1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	if err := db.Put(nil, []byte("key"), []byte("v2")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ro := GetReadOptions()
+				if ro.snap != nil || !ro.fillCache || ro.verifyChecksums {
+					t.Errorf("pooled ReadOptions not reset: snap %v, fillCache %v, verifyChecksums %v",
+						ro.snap, ro.fillCache, ro.verifyChecksums)
+				}
+				CheckGet(t, "pooled ReadOptions", db, ro, []byte("key"), []byte("v2"))
+				ro.SetSnapshot(snap)
+				ro.SetFillCache(false)
+				ro.SetVerifyChecksums(true)
+				CheckGet(t, "pooled ReadOptions with snapshot", db, ro, []byte("key"), []byte("v1"))
+				PutReadOptions(ro)
+
+				wo := GetWriteOptions()
+				if wo.sync {
+					t.Errorf("pooled WriteOptions not reset")
+				}
+				wo.SetSync(i%2 == 0)
+				if err := db.Put(wo, []byte(fmt.Sprintf("g%02d", g)), []byte("value")); err != nil {
+					t.Errorf("Put failed: %v", err)
+				}
+				PutWriteOptions(wo)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// destroyed options are not pooled
+	ro := GetReadOptions()
+	ro.Destroy()
+	PutReadOptions(ro)
+	if ro = GetReadOptions(); ro.opt == nil {
+		t.Errorf("GetReadOptions returned a destroyed ReadOptions")
+	}
+	PutReadOptions(ro)
+}
+
+func TestHas(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("large"), bytes.Repeat([]byte("v"), 1<<20))
+	db.Put(nil, []byte("empty"), nil)
+	db.Put(nil, []byte("deleted"), []byte("value"))
+	db.Delete(nil, []byte("deleted"))
+
+	for _, test := range []struct {
+		key  string
+		want bool
+	}{
+		{"large", true},
+		{"empty", true},
+		{"deleted", false},
+		{"missing", false},
+	} {
+		has, err := db.Has(nil, []byte(test.key))
+		if err != nil {
+			t.Errorf("Has(%q) failed: %v", test.key, err)
+		} else if has != test.want {
+			t.Errorf("Has(%q) = %v, want %v", test.key, has, test.want)
+		}
+	}
+}
+
+func TestIteratorPool(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	EnableLeakTracking(true)
+	defer EnableLeakTracking(false)
+
+	for i := 0; i < 100; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+	}
+	pool := db.NewIteratorPool(nil)
+	// not seen by the iterators of the pool
+	db.Put(nil, []byte("key100"), []byte("value"))
+	db.Delete(nil, []byte("key000"))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				it := pool.Get()
+				n := 0
+				for it.SeekToFirst(); it.Valid(); it.Next() {
+					n++
+				}
+				if err := it.Error(); err != nil {
+					t.Errorf("iteration failed: %v", err)
+				}
+				if n != 100 {
+					t.Errorf("pooled iterator saw %d keys, want 100", n)
+				}
+				it.Seek([]byte("key000"))
+				if !it.Valid() || string(it.Key()) != "key000" {
+					t.Errorf("pooled iterator doesn't read from the snapshot of the pool")
+				}
+				pool.Put(it)
+			}
+		}()
+	}
+	wg.Wait()
+
+	it := pool.Get()
+	pool.Close()
+	pool.Close()
+	pool.Put(it)
+
+	var report bytes.Buffer
+	if n := ReportLeaks(&report); n != 0 {
+		t.Errorf("%d resources leaked after closing the pool:\n%s", n, report.String())
+	}
+	if n := db.SnapshotCount(); n != 0 {
+		t.Errorf("%d snapshots left after closing the pool", n)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for i := 0; i < 100; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+	}
+	if err := db.DeleteRange(nil, Range{Start: []byte("key20"), Limit: []byte("key70")}); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%02d", i))
+		if i >= 20 && i < 70 {
+			CheckGet(t, "deleted range", db, nil, key, nil)
+		} else {
+			CheckGet(t, "outside the deleted range", db, nil, key, []byte("value"))
+		}
+	}
+
+	// unbounded start, with a comparator ordering the keys in reverse
+	dbname2 := tempDir(t)
+	defer deleteDBDirectory(t, dbname2)
+	cmp := NewComparator("goleveldb.reverse", func(a, b []byte) int { return bytes.Compare(b, a) })
+	defer cmp.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(cmp)
+	rdb, err := Open(dbname2, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer rdb.Close()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		rdb.Put(nil, []byte(key), []byte("value"))
+	}
+	if err := rdb.DeleteRange(nil, Range{Limit: []byte("b")}); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		CheckGet(t, "after the reverse range", rdb, nil, []byte(key), []byte("value"))
+	}
+	for _, key := range []string{"c", "d"} {
+		CheckGet(t, "in the reverse range", rdb, nil, []byte(key), nil)
+	}
+}
+
+func TestReadOptionsClone(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("key"), []byte("v1"))
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	db.Put(nil, []byte("key"), []byte("v2"))
+
+	base := NewReadOptions()
+	defer base.Destroy()
+	base.SetVerifyChecksums(true)
+	base.SetSnapshot(snap)
+
+	clone := base.Clone()
+	defer clone.Destroy()
+	if clone.opt == base.opt {
+		t.Fatalf("Clone shares the C options")
+	}
+	if !clone.VerifyChecksums() || !clone.FillCache() || clone.Snapshot() != snap {
+		t.Errorf("Clone settings differ from the original")
+	}
+	CheckGet(t, "clone", db, clone, []byte("key"), []byte("v1"))
+
+	clone.SetFillCache(false)
+	clone.SetVerifyChecksums(false)
+	clone.SetSnapshot(nil)
+	if !base.VerifyChecksums() || !base.FillCache() || base.Snapshot() != snap {
+		t.Errorf("modifying the clone changed the original")
+	}
+	if clone.VerifyChecksums() || clone.FillCache() || clone.Snapshot() != nil {
+		t.Errorf("getters of the modified clone = %v, %v, %v",
+			clone.VerifyChecksums(), clone.FillCache(), clone.Snapshot())
+	}
+	CheckGet(t, "clone without snapshot", db, clone, []byte("key"), []byte("v2"))
+	CheckGet(t, "original", db, base, []byte("key"), []byte("v1"))
+}
+
+func TestView(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("a"), []byte("v1"))
+	err := db.View(func(snap *Snapshot) error {
+		db.Put(nil, []byte("a"), []byte("v2"))
+		db.Put(nil, []byte("b"), []byte("v2"))
+
+		if value, err := db.GetAt(snap, []byte("a")); err != nil || string(value) != "v1" {
+			t.Errorf("GetAt = %q, %v, want the value of the snapshot", value, err)
+		}
+		if _, err := db.GetAt(snap, []byte("b")); err != ErrNotFound {
+			t.Errorf("GetAt a key put after the snapshot = %v, want ErrNotFound", err)
+		}
+		it := db.NewIteratorAt(snap)
+		defer it.Close()
+		var keys []string
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Key())+"="+string(it.Value()))
+		}
+		if strings.Join(keys, ",") != "a=v1" {
+			t.Errorf("NewIteratorAt read %v, want [a=v1]", keys)
+		}
+		return io.EOF
+	})
+	if err != io.EOF {
+		t.Errorf("View returned %v, want the error of fn", err)
+	}
+	CheckGet(t, "after View", db, nil, []byte("a"), []byte("v2"))
+
+	func() {
+		defer func() { recover() }()
+		db.View(func(*Snapshot) error { panic("fn") })
+	}()
+	if n := db.SnapshotCount(); n != 0 {
+		t.Errorf("%d snapshots left after View", n)
+	}
+}
+
+func TestNumFilesAtLevel(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetWriteBufferSize(64 << 10)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	if n, err := db.NumFilesAtLevel(0); err != nil || n != 0 {
+		t.Errorf("NumFilesAtLevel(0) of an empty DB = %d, %v", n, err)
+	}
+	if _, err := db.NumFilesAtLevel(-1); err == nil {
+		t.Errorf("NumFilesAtLevel(-1) succeeded")
+	}
+	if _, err := db.NumFilesAtLevel(numLevels); err == nil {
+		t.Errorf("NumFilesAtLevel(%d) succeeded", numLevels)
+	}
+
+	// Fill write buffers over the same keys: the first flushes are pushed to
+	// deeper levels, the ones overlapping them stay at level 0.
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := 0; i < 400; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i%50)), value)
+	}
+	time.Sleep(100 * time.Millisecond)
+	n, err := db.NumFilesAtLevel(0)
+	if err != nil || n <= 0 {
+		t.Errorf("NumFilesAtLevel(0) = %d, %v, want a positive count", n, err)
+	}
+	counts, err := db.LevelFileCounts()
+	if err != nil {
+		t.Fatalf("LevelFileCounts failed: %v", err)
+	}
+	if len(counts) != numLevels || counts[0] <= 0 {
+		t.Errorf("LevelFileCounts = %v", counts)
+	}
+
+	sstables, err := db.SSTables()
+	if err != nil || !strings.Contains(sstables, "--- level 0 ---\n ") {
+		t.Errorf("SSTables = %q, %v, want level 0 files", sstables, err)
+	}
+	stats, err := db.StatsText()
+	if err != nil || !strings.Contains(stats, "Level  Files") {
+		t.Errorf("StatsText = %q, %v", stats, err)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	srcname := tempDir(t)
+	defer deleteDBDirectory(t, srcname)
+	src := openTestDB(t, srcname)
+	defer src.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	want := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := make([]byte, 1+rnd.Intn(32))
+		value := make([]byte, rnd.Intn(300))
+		rnd.Read(key)
+		rnd.Read(value)
+		src.Put(nil, key, value)
+		want[string(key)] = string(value)
+	}
+	src.Put(nil, nil, nil)
+	want[""] = ""
+
+	var backup bytes.Buffer
+	if err := src.Backup(&backup, nil); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	dstname := tempDir(t)
+	defer deleteDBDirectory(t, dstname)
+	dst := openTestDB(t, dstname)
+	defer dst.Close()
+	if err := dst.Restore(bytes.NewReader(backup.Bytes()), nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	it := dst.NewIterator(nil)
+	defer it.Close()
+	n := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		value, ok := want[string(it.Key())]
+		if !ok || value != string(it.Value()) {
+			t.Fatalf("restored entry %q differs", it.Key())
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Errorf("restored %d entries, want %d", n, len(want))
+	}
+
+	// a truncated stream is reported
+	truncated := backup.Bytes()[:backup.Len()-1]
+	if err := dst.Restore(bytes.NewReader(truncated), nil); err != io.ErrUnexpectedEOF {
+		t.Errorf("Restore of a truncated stream = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	key := []byte("key")
+	for _, test := range []struct {
+		old, new []byte
+		swapped  bool
+		value    []byte // after the call, nil if missing
+	}{
+		{[]byte("v1"), []byte("v2"), false, nil}, // missing key isn't "v1"
+		{nil, []byte("v1"), true, []byte("v1")},  // missing key is nil
+		{nil, []byte("v2"), false, []byte("v1")},
+		{[]byte("v2"), []byte("v3"), false, []byte("v1")},
+		{[]byte("v1"), []byte{}, true, []byte{}},
+		{nil, []byte("v2"), false, []byte{}}, // empty value isn't missing
+		{[]byte{}, nil, true, nil},           // nil new deletes
+	} {
+		swapped, err := db.CompareAndSwap(nil, key, test.old, test.new)
+		if err != nil {
+			t.Fatalf("CompareAndSwap(%q, %q) failed: %v", test.old, test.new, err)
+		}
+		if swapped != test.swapped {
+			t.Errorf("CompareAndSwap(%q, %q) = %v, want %v", test.old, test.new, swapped, test.swapped)
+		}
+		value, err := db.Get(nil, key)
+		if test.value == nil {
+			if err != ErrNotFound {
+				t.Errorf("after CompareAndSwap(%q, %q), Get = %q, %v, want ErrNotFound", test.old, test.new, value, err)
+			}
+		} else if err != nil || !bytes.Equal(value, test.value) {
+			t.Errorf("after CompareAndSwap(%q, %q), Get = %q, %v, want %q", test.old, test.new, value, err, test.value)
+		}
+	}
+
+	// concurrent increments don't lose updates
+	db.Put(nil, key, []byte("0"))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				for {
+					old, _ := db.Get(nil, key)
+					n, _ := strconv.Atoi(string(old))
+					swapped, err := db.CompareAndSwap(nil, key, old, []byte(strconv.Itoa(n+1)))
+					if err != nil {
+						t.Errorf("CompareAndSwap failed: %v", err)
+						return
+					}
+					if swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	CheckGet(t, "after concurrent increments", db, nil, key, []byte("400"))
+}
+
+func TestParseStats(t *testing.T) {
+	const captured = "                               Compactions\n" +
+		"Level  Files Size(MB) Time(sec) Read(MB) Write(MB)\n" +
+		"--------------------------------------------------\n" +
+		"  0        2        1         0        0         1\n" +
+		"  1        0        0         0        0         0\n" +
+		"  2        5       10         3       12        11\n"
+	levels, err := parseStats(captured)
+	if err != nil {
+		t.Fatalf("parseStats failed: %v", err)
+	}
+	want := []LevelStats{
+		{Level: 0, Files: 2, SizeMB: 1, WriteMB: 1},
+		{Level: 2, Files: 5, SizeMB: 10, TimeSec: 3, ReadMB: 12, WriteMB: 11},
+	}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("parseStats = %+v, want %+v", levels, want)
+	}
+
+	if _, err := parseStats("  0        2        x         0        0         1\n"); err == nil {
+		t.Errorf("parseStats of a malformed line succeeded")
+	}
+
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+	db.Put(nil, []byte("key"), []byte("value"))
+	db.CompactRange(nil, nil)
+	levels, err = db.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	files := 0
+	for _, l := range levels {
+		files += l.Files
+	}
+	if files != 1 {
+		t.Errorf("Stats = %+v, want 1 file in all", levels)
+	}
+}
+
+func TestIteratorSeekForPrev(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for _, key := range []string{"b", "d", "f"} {
+		db.Put(nil, []byte(key), []byte("value"))
+	}
+	it := db.NewIterator(nil)
+	defer it.Close()
+	rit := db.NewRangeIterator(nil, Range{Start: []byte("c"), Limit: []byte("e")})
+	defer rit.Close()
+
+	for _, test := range []struct {
+		it     *Iterator
+		target string
+		want   string // "" if invalid
+	}{
+		{it, "d", "d"}, // exact match
+		{it, "e", "d"}, // lower neighbor
+		{it, "z", "f"}, // past the last key
+		{it, "a", ""},  // below the first key
+		{it, "b", "b"},
+		{rit, "z", "d"}, // past the limit
+		{rit, "c", ""},  // below the start
+	} {
+		test.it.SeekForPrev([]byte(test.target))
+		got := ""
+		if test.it.Valid() {
+			got = string(test.it.Key())
+		}
+		if got != test.want {
+			t.Errorf("SeekForPrev(%q) moved to %q, want %q", test.target, got, test.want)
+		}
+	}
+}
+
+func TestWriteOptionsClone(t *testing.T) {
+	base := NewWriteOptions()
+	defer base.Destroy()
+	if base.Sync() {
+		t.Errorf("Sync of new WriteOptions = true")
+	}
+
+	clone := base.Clone()
+	defer clone.Destroy()
+	if clone.opt == base.opt {
+		t.Fatalf("Clone shares the C options")
+	}
+	clone.SetSync(true)
+	if !clone.Sync() || base.Sync() {
+		t.Errorf("after SetSync(true) on the clone, Sync = %v, original %v", clone.Sync(), base.Sync())
+	}
+
+	syncClone := clone.Clone()
+	defer syncClone.Destroy()
+	if !syncClone.Sync() {
+		t.Errorf("Clone didn't copy the sync setting")
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	EnableLeakTracking(true)
+	defer EnableLeakTracking(false)
+
+	db.Put(nil, []byte("key"), []byte("value"))
+	db.Put(nil, []byte("empty"), nil)
+	for _, key := range []string{"key", "empty"} {
+		want, _ := db.Get(nil, []byte(key))
+		v, err := db.GetRaw(nil, []byte(key))
+		if err != nil {
+			t.Fatalf("GetRaw(%q) failed: %v", key, err)
+		}
+		if !bytes.Equal(v.Bytes(), want) || v.Len() != len(want) {
+			t.Errorf("GetRaw(%q) = %q, want %q", key, v.Bytes(), want)
+		}
+		v.Free()
+		v.Free()
+		if v.Bytes() != nil || v.Len() != 0 {
+			t.Errorf("freed CValue still has a value")
+		}
+	}
+	if _, err := db.GetRaw(nil, []byte("missing")); err != ErrNotFound {
+		t.Errorf("GetRaw of a missing key = %v, want ErrNotFound", err)
+	}
+
+	var report bytes.Buffer
+	if n := ReportLeaks(&report); n != 0 {
+		t.Errorf("%d resources leaked:\n%s", n, report.String())
+	}
+}
+
+func TestPutMany(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if err := db.PutMany(nil, nil); err != nil {
+		t.Errorf("PutMany of nothing failed: %v", err)
+	}
+	err := db.PutMany(nil, []KV{
+		{[]byte("a"), []byte("1")},
+		{[]byte("b"), []byte("2")},
+		{[]byte("a"), []byte("3")},
+	})
+	if err != nil {
+		t.Fatalf("PutMany failed: %v", err)
+	}
+	CheckGet(t, "overwritten in the batch", db, nil, []byte("a"), []byte("3"))
+	CheckGet(t, "PutMany", db, nil, []byte("b"), []byte("2"))
+}
+
+func TestEnv(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	env := NewDefaultEnv()
+	defer env.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetEnv(env)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	db.Put(nil, []byte("key"), []byte("value"))
+	CheckGet(t, "default Env", db, nil, []byte("key"), []byte("value"))
+	db.Close()
+	env.Destroy()
+}
+
+func TestCountRange(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if n, err := db.CountRange(nil, Range{}); err != nil || n != 0 {
+		t.Errorf("CountRange of an empty DB = %d, %v", n, err)
+	}
+	for i := 0; i < 100; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%02d", i)), []byte("value"))
+	}
+	for _, test := range []struct {
+		r    Range
+		want int
+	}{
+		{Range{}, 100},
+		{Range{Start: []byte("key20"), Limit: []byte("key70")}, 50},
+		{Range{Start: []byte("key905")}, 9},
+		{Range{Limit: []byte("key10")}, 10},
+		{Range{Start: []byte("x")}, 0},
+	} {
+		n, err := db.CountRange(nil, test.r)
+		if err != nil || n != test.want {
+			t.Errorf("CountRange(%q, %q) = %d, %v, want %d", test.r.Start, test.r.Limit, n, err, test.want)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	if size := db.Size(); size != 0 {
+		t.Errorf("Size of an empty DB = %d", size)
+	}
+	value := bytes.Repeat([]byte("v"), 1000)
+	write := func(prefix string) {
+		for i := 0; i < 1000; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("%s%04d", prefix, i)), value)
+		}
+		db.CompactRange(nil, nil)
+	}
+	write("a")
+	small := db.Size()
+	if small < 500<<10 {
+		t.Errorf("Size after writing 1MB = %d", small)
+	}
+	write("b")
+	large := db.Size()
+	if large < small+500<<10 {
+		t.Errorf("Size after writing 1MB more = %d, was %d", large, small)
+	}
+
+	if a := db.SizeOf([]byte("a")); a < small/2 || a > small*2 {
+		t.Errorf("SizeOf(a) = %d, want about %d", a, small)
+	}
+	// every key starts with the empty prefix
+	if all := db.SizeOf(nil); all != large {
+		t.Errorf("SizeOf(nil) = %d, want Size %d", all, large)
+	}
+	if none := db.SizeOf([]byte("c")); none != 0 {
+		t.Errorf("SizeOf(c) = %d, want 0", none)
+	}
+}
+
+func TestSizeOfComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	reverse := NewComparator("goleveldb.test.reverse", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	value := bytes.Repeat([]byte("v"), 1000)
+	for _, prefix := range []string{"a", "b"} {
+		for i := 0; i < 1000; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("%s%04d", prefix, i)), value)
+		}
+	}
+	db.CompactRange(nil, nil)
+
+	size := db.Size()
+	if size < 1<<20 {
+		t.Errorf("Size after writing 2MB = %d", size)
+	}
+	for _, prefix := range []string{"a", "b"} {
+		if n := db.SizeOf([]byte(prefix)); n < size/4 || n > size*3/4 {
+			t.Errorf("SizeOf(%s) = %d, want about %d", prefix, n, size/2)
+		}
+	}
+	if none := db.SizeOf([]byte("c")); none != 0 {
+		t.Errorf("SizeOf(c) = %d, want 0", none)
+	}
+}
+
+func TestWriteBatchPool(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	var pool WriteBatchPool
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				wb := pool.Get()
+				if wb.Count() != 0 || wb.ApproximateSize() != 0 {
+					t.Errorf("pooled batch not empty: Count = %d", wb.Count())
+				}
+				wb.Put([]byte(fmt.Sprintf("g%d", g)), []byte(strconv.Itoa(i)))
+				if err := db.Write(nil, wb); err != nil {
+					t.Errorf("Write failed: %v", err)
+				}
+				pool.Put(wb)
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g := 0; g < 8; g++ {
+		CheckGet(t, "pooled batches", db, nil, []byte(fmt.Sprintf("g%d", g)), []byte("99"))
+	}
+
+	// a recycled batch writes nothing of its previous use
+	wb := pool.Get()
+	wb.Put([]byte("stale"), []byte("value"))
+	pool.Put(wb)
+	wb = pool.Get()
+	if err := db.Write(nil, wb); err != nil {
+		t.Errorf("Write failed: %v", err)
+	}
+	pool.Put(wb)
+	CheckGet(t, "recycled batch", db, nil, []byte("stale"), nil)
+}
+
+func TestSnapshotReleased(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("key"), []byte("value"))
+	s1 := db.GetSnapshot()
+	s2 := db.GetSnapshot()
+	defer db.ReleaseSnapshot(s2)
+	if s1.ID() == s2.ID() {
+		t.Errorf("two snapshots have the same ID %d", s1.ID())
+	}
+	if s1.Released() || s2.Released() {
+		t.Errorf("new snapshots are released")
+	}
+
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(s1)
+	it := db.NewIterator(ro)
+	defer it.Close()
+	CheckGet(t, "before release", db, ro, []byte("key"), []byte("value"))
+
+	db.ReleaseSnapshot(s1)
+	db.ReleaseSnapshot(s1)
+	if !s1.Released() || s2.Released() {
+		t.Errorf("after releasing s1, Released = %v, %v", s1.Released(), s2.Released())
+	}
+	if n := db.SnapshotCount(); n != 1 {
+		t.Errorf("SnapshotCount = %d, want 1", n)
+	}
+
+	if _, err := db.Get(nil, []byte("key")); err != nil {
+		t.Errorf("Get without snapshot failed: %v", err)
+	}
+	if _, err := db.Get(ro, []byte("key")); err != ErrSnapshotReleased {
+		t.Errorf("Get through a released snapshot = %v, want ErrSnapshotReleased", err)
+	}
+	it2 := db.NewIterator(ro)
+	defer it2.Close()
+	it2.SeekToFirst()
+	if it2.Valid() || it2.Error() != ErrSnapshotReleased {
+		t.Errorf("iterator with a released snapshot: Valid = %v, Error = %v", it2.Valid(), it2.Error())
+	}
+	it.Reset(ro)
+	if it.Error() != ErrSnapshotReleased {
+		t.Errorf("Reset with a released snapshot: Error = %v", it.Error())
+	}
+
+	// setting a released snapshot
+	ro2 := NewReadOptions()
+	defer ro2.Destroy()
+	if err := ro2.SetSnapshotChecked(s1); err != ErrSnapshotReleased || ro2.Snapshot() != nil {
+		t.Errorf("SetSnapshotChecked of a released snapshot = %v", err)
+	}
+	if err := ro2.SetSnapshotChecked(s2); err != nil || ro2.Snapshot() != s2 {
+		t.Errorf("SetSnapshotChecked = %v", err)
+	}
+	ro2.SetSnapshot(s1)
+	if _, err := db.Get(ro2, []byte("key")); err != ErrSnapshotReleased {
+		t.Errorf("Get through a snapshot released before being set = %v, want ErrSnapshotReleased", err)
+	}
+}
+
+func TestSyncWrites(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	EnableLeakTracking(true)
+	defer EnableLeakTracking(false)
+
+	db := openTestDB(t, dbname)
+	if err := db.PutSync([]byte("put"), []byte("value")); err != nil {
+		t.Errorf("PutSync failed: %v", err)
+	}
+	db.Put(nil, []byte("deleted"), []byte("value"))
+	if err := db.DeleteSync([]byte("deleted")); err != nil {
+		t.Errorf("DeleteSync failed: %v", err)
+	}
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	wb.Put([]byte("batch"), []byte("value"))
+	if err := db.WriteSync(wb); err != nil {
+		t.Errorf("WriteSync failed: %v", err)
+	}
+	db.Close()
+	if err := db.PutSync([]byte("put"), []byte("value")); err != ErrClosed {
+		t.Errorf("PutSync after Close = %v, want ErrClosed", err)
+	}
+	// Close destroyed the sync WriteOptions, only the batch is alive
+	if n := ReportLeaks(io.Discard); n != 1 {
+		t.Errorf("%d resources alive after Close, want 1", n)
+	}
+
+	options := NewOptions()
+	defer options.Destroy()
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	CheckGet(t, "after reopening", db, nil, []byte("put"), []byte("value"))
+	CheckGet(t, "after reopening", db, nil, []byte("deleted"), nil)
+	CheckGet(t, "after reopening", db, nil, []byte("batch"), []byte("value"))
+}
+
+func TestOpenVerified(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	value := bytes.Repeat([]byte("v"), 100)
+	for i := 0; i < 10000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%05d", i)), value)
+	}
+	db.CompactRange(nil, nil)
+	db.Close()
+
+	for _, vo := range []*VerifyOptions{nil, {Sample: 0.1}} {
+		db, err := OpenVerified(dbname, nil, vo)
+		if err != nil {
+			t.Fatalf("OpenVerified of a healthy DB failed: %v", err)
+		}
+		CheckGet(t, "OpenVerified", db, nil, []byte("key00042"), value)
+		db.Close()
+	}
+
+	// a damaged block at the start of each table
+	if corruptTableFiles(t, dbname) == 0 {
+		t.Fatalf("no table files to corrupt")
+	}
+	db, err := OpenVerified(dbname, nil, nil)
+	if db != nil {
+		db.Close()
+	}
+	var verr *VerifyError
+	if !errors.As(err, &verr) || !isCorruption(err) {
+		t.Fatalf("OpenVerified of a corrupted DB = %v, want a *VerifyError", err)
+	}
+	if n := len(verr.Ranges); n == 0 || n == verifySegments || verr.Ranges[0].Start != nil {
+		t.Errorf("OpenVerified found errors in %d ranges, want the first few", n)
+	}
+
+	// a truncated table
+	tables := tableFiles(dbname)
+	if err := os.Truncate(tables[len(tables)-1], 100); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	_, err = OpenVerified(dbname, nil, nil)
+	if !errors.As(err, &verr) || !(isCorruption(err) || errors.Is(err, ErrIOError)) {
+		t.Errorf("OpenVerified with a truncated table = %v, want a corruption or an IO error", err)
+	}
+}
+
+func TestWriteChunked(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for i := 0; i < 9000; i++ {
+		wb.Put([]byte(fmt.Sprintf("key%05d", i)), []byte(strconv.Itoa(i)))
+	}
+	// Deletions of keys put in earlier chunks.
+	for i := 0; i < 1000; i++ {
+		wb.Delete([]byte(fmt.Sprintf("key%05d", 2*i)))
+	}
+	if err := db.WriteChunked(nil, wb, 1000); err != nil {
+		t.Fatalf("WriteChunked failed: %v", err)
+	}
+
+	it := db.NewIterator(nil)
+	defer it.Close()
+	n := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		i, _ := strconv.Atoi(string(it.Key()[3:]))
+		if (i < 2000 && i%2 == 0) || string(it.Value()) != strconv.Itoa(i) {
+			t.Fatalf("unexpected entry %q = %q", it.Key(), it.Value())
+		}
+		n++
+	}
+	if n != 8000 {
+		t.Errorf("%d keys after WriteChunked, want 8000", n)
+	}
+
+	// The full chunks are written as they fill up.
+	b := db.NewChunkedBatch(nil, 1000)
+	defer b.Destroy()
+	for i := 0; i < 1500; i++ {
+		if err := b.Delete([]byte(fmt.Sprintf("key%05d", 8999-i))); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	CheckGet(t, "first chunk", db, nil, []byte("key08000"), nil)
+	CheckGet(t, "pending chunk", db, nil, []byte("key07999"), []byte("7999"))
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	CheckGet(t, "flushed chunk", db, nil, []byte("key07999"), nil)
+}
+
+func TestInfoLog(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	var mu sync.Mutex
+	var lines []string
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetInfoLog(func(msg string) {
+		mu.Lock()
+		lines = append(lines, msg)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 2; i++ {
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		db.Put(nil, []byte("key"), []byte("value"))
+		db.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(strings.Join(lines, "\n"), "Recovering log") {
+		t.Errorf("the recovery wasn't logged, got %q", lines)
+	}
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\n") || strings.HasPrefix(line, "20") {
+			t.Errorf("line %q has a newline or a timestamp", line)
+		}
+	}
+}
+
+func TestInfoLogFile(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	logdir := tempDir(t)
+	if err := os.MkdirAll(logdir, 0755); err != nil {
+		t.Fatalf("creating the info log directory: %v", err)
+	}
+	defer os.RemoveAll(logdir)
+	logname := filepath.Join(logdir, "info.log")
+
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetInfoLogFile(logname)
+
+	for i := 0; i < 2; i++ {
+		db, err := Open(dbname, options)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		db.Put(nil, []byte("key"), []byte("value"))
+		db.Close()
+	}
+
+	data, err := os.ReadFile(logname)
+	if err != nil {
+		t.Fatalf("reading the info log file: %v", err)
+	}
+	// The lines of both opens, as written to the LOG file.
+	if n := strings.Count(string(data), "Recovering log"); n != 1 {
+		t.Errorf("%d recoveries logged, want 1:\n%s", n, data)
+	}
+	if !strings.HasPrefix(string(data), "20") || !strings.HasSuffix(string(data), "\n") {
+		t.Errorf("info log file not made of LOG lines:\n%s", data)
+	}
+
+	options.SetInfoLogFile(filepath.Join(dbname, "missing", "info.log"))
+	if db, err := Open(dbname, options); err == nil {
+		db.Close()
+		t.Errorf("Open succeeded with an info log file that can't be created")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+
+	files := func() int {
+		counts, err := db.LevelFileCounts()
+		if err != nil {
+			t.Fatalf("LevelFileCounts failed: %v", err)
+		}
+		n := 0
+		for _, c := range counts {
+			n += c
+		}
+		return n
+	}
+
+	for i := 0; i < 100; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+	}
+	if n := files(); n != 0 {
+		t.Fatalf("%d table files before Flush, want the writes in the memtable", n)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n := files(); n == 0 {
+		t.Errorf("no table file after Flush")
+	}
+	if n := len(tableFiles(dbname)); n == 0 {
+		t.Errorf("no table file on disk after Flush")
+	}
+
+	db.Close()
+	if err := db.Flush(); err != ErrClosed {
+		t.Errorf("Flush after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestSetBlockCache(t *testing.T) {
+	cache := NewLRUCache(1 << 20)
+	defer cache.Destroy()
+
+	for _, test := range []struct {
+		name     string
+		cache    *Cache
+		capacity uint64
+	}{
+		{"no block cache", nil, 0},
+		{"block cache", cache, 1 << 20},
+	} {
+		dbname := tempDir(t)
+		options := NewOptions()
+		options.SetCreateIfMissing(true)
+		options.SetBlockCache(test.cache)
+		if options.cache == nil || options.cache.Capacity() != test.capacity {
+			t.Errorf("%s: Options cache = %v", test.name, options.cache)
+		}
+		db, err := Open(dbname, options)
+		options.Destroy()
+		if err != nil {
+			t.Fatalf("%s: Open failed: %v", test.name, err)
+		}
+
+		for i := 0; i < 100; i++ {
+			db.Put(nil, []byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+		}
+		db.CompactRange(nil, nil)
+		for i := 0; i < 100; i++ {
+			CheckGet(t, test.name, db, nil, []byte(fmt.Sprintf("key%03d", i)), []byte("value"))
+		}
+		db.Close()
+		deleteDBDirectory(t, dbname)
+	}
+}
+
+func TestApproximateKeyCount(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	// Entries of about the same size, as the estimate expects.
+	rnd := rand.New(rand.NewSource(1))
+	value := make([]byte, 100)
+	put := func(i int) {
+		rnd.Read(value)
+		db.Put(nil, []byte(fmt.Sprintf("key%06d", i)), value)
+	}
+	for i := 0; i < 1000; i++ {
+		put(i)
+	}
+	if n, err := db.CountExact(nil); err != nil || n != 1000 {
+		t.Errorf("CountExact = %d, %v, want 1000", n, err)
+	}
+	// Few keys are counted exactly.
+	if n, err := db.ApproximateKeyCount(nil); err != nil || n != 1000 {
+		t.Errorf("ApproximateKeyCount of a small DB = %d, %v, want 1000", n, err)
+	}
+
+	for i := 1000; i < 200000; i++ {
+		put(i)
+	}
+	db.CompactRange(nil, nil)
+	if n, err := db.CountExact(nil); err != nil || n != 200000 {
+		t.Errorf("CountExact = %d, %v, want 200000", n, err)
+	}
+	n, err := db.ApproximateKeyCount(nil)
+	if err != nil || n < 180000 || n > 220000 {
+		t.Errorf("ApproximateKeyCount = %d, %v, want 200000 +/- 10%%", n, err)
+	}
+
+	db.Close()
+	if _, err := db.ApproximateKeyCount(nil); err != ErrClosed {
+		t.Errorf("ApproximateKeyCount after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestApproximateSize(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), value)
+	}
+	db.CompactRange(nil, nil)
+
+	ranges := []Range{
+		{[]byte("key0000"), []byte("key0500")},
+		{[]byte("key0500"), []byte("key1000")},
+		{[]byte("a"), []byte("b")},
+	}
+	sizes := db.GetApproximateSizes(ranges)
+	for i, r := range ranges {
+		if size := db.ApproximateSize(r); size != sizes[i] {
+			t.Errorf("ApproximateSize(%q, %q) = %d, GetApproximateSizes gives %d", r.Start, r.Limit, size, sizes[i])
+		}
+	}
+	if sizes[0] == 0 || sizes[2] != 0 {
+		t.Errorf("GetApproximateSizes = %v", sizes)
+	}
+
+	db.Close()
+	if size := db.ApproximateSize(ranges[0]); size != 0 {
+		t.Errorf("ApproximateSize after Close = %d", size)
+	}
+}
+
+func TestCompactAllAsync(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+
+	value := bytes.Repeat([]byte("v"), 1000)
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), value)
+	}
+
+	// The second call joins the compaction started by the first.
+	first, second := db.CompactAllAsync(), db.CompactAllAsync()
+	for i, done := range []<-chan error{first, second} {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("compaction %d failed: %v", i, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("compaction %d didn't complete", i)
+		}
+	}
+	counts, err := db.LevelFileCounts()
+	if err != nil {
+		t.Fatalf("LevelFileCounts failed: %v", err)
+	}
+	if counts[0] != 0 {
+		t.Errorf("%d files left at level 0 after the compaction", counts[0])
+	}
+	CheckGet(t, "after CompactAllAsync", db, nil, []byte("key0500"), value)
+
+	// A new compaction starts once the previous one is done.
+	if err := <-db.CompactAllAsync(); err != nil {
+		t.Errorf("new compaction failed: %v", err)
+	}
+
+	db.Close()
+	if err := <-db.CompactAllAsync(); err != ErrClosed {
+		t.Errorf("CompactAllAsync after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestCompactPrefix(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	value := make([]byte, 1000)
+	for _, prefix := range []string{"a:", "b:", "\xff\xff"} {
+		for i := 0; i < 1000; i++ {
+			rnd.Read(value)
+			db.Put(nil, []byte(fmt.Sprintf("%s%04d", prefix, i)), value)
+		}
+	}
+	db.CompactRange(nil, nil)
+
+	for _, prefix := range []string{"a:", "\xff\xff"} {
+		before := db.SizeOf([]byte(prefix))
+		it := db.NewPrefixIterator(nil, []byte(prefix))
+		for ; it.Valid(); it.Next() {
+			db.Delete(nil, it.Key())
+		}
+		it.Close()
+
+		db.CompactPrefix([]byte(prefix))
+		if after := db.SizeOf([]byte(prefix)); after > before/10 {
+			t.Errorf("SizeOf(%q) = %d after CompactPrefix, %d before", prefix, after, before)
+		}
+	}
+	if size := db.SizeOf([]byte("b:")); size < 500000 {
+		t.Errorf("SizeOf(%q) = %d, want the keys left in place", "b:", size)
+	}
+}
+
+func TestCompactPrefixComparator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	reverse := NewComparator("goleveldb.test.reverse", func(a, b []byte) int {
+		return bytes.Compare(b, a)
+	})
+	defer reverse.Destroy()
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetComparator(reverse)
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	rnd := rand.New(rand.NewSource(1))
+	value := make([]byte, 1000)
+	for _, prefix := range []string{"a:", "b:"} {
+		for i := 0; i < 1000; i++ {
+			rnd.Read(value)
+			db.Put(nil, []byte(fmt.Sprintf("%s%04d", prefix, i)), value)
+		}
+	}
+	db.CompactRange(nil, nil)
+
+	before := db.Size()
+	it := db.NewPrefixIterator(nil, []byte("a:"))
+	for ; it.Valid(); it.Next() {
+		db.Delete(nil, it.Key())
+	}
+	it.Close()
+	db.CompactPrefix([]byte("a:"))
+	if after := db.Size(); after > before*3/4 {
+		t.Errorf("Size = %d after CompactPrefix, %d before", after, before)
+	}
+	if n := db.SizeOf([]byte("b:")); n < 500000 {
+		t.Errorf("SizeOf(%q) = %d, want the keys left in place", "b:", n)
+	}
+}
+
+func TestNewReverseIterator(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	for _, k := range keys {
+		db.Put(nil, []byte(k), []byte(strings.ToUpper(k)))
+	}
+
+	it := db.NewReverseIterator(nil)
+	defer it.Close()
+	var got []string
+	for ; it.Valid(); it.Next() {
+		got = append(got, string(it.Key())+"="+string(it.Value()))
+	}
+	if s := strings.Join(got, ","); s != "e=E,d=D,c=C,b=B,a=A" {
+		t.Errorf("reverse scan = %s", s)
+	}
+	if err := it.Error(); err != nil {
+		t.Errorf("reverse scan failed: %v", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		move func()
+		want string // "" if invalid
+	}{
+		{"SeekToFirst", it.SeekToFirst, "e"},
+		{"SeekToLast", it.SeekToLast, "a"},
+		{"Seek(cc)", func() { it.Seek([]byte("cc")) }, "c"},
+		{"SeekForPrev(cc)", func() { it.SeekForPrev([]byte("cc")) }, "d"},
+		{"Prev", it.Prev, "e"},
+		{"Prev past the last key", it.Prev, ""},
+	} {
+		test.move()
+		got := ""
+		if it.Valid() {
+			got = string(it.Key())
+		}
+		if got != test.want {
+			t.Errorf("%s moved to %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	it.SeekToFirst()
+	batch, _, done := it.NextBatch(3)
+	if len(batch) != 3 || string(batch[0]) != "e" || string(batch[2]) != "c" || done {
+		t.Errorf("NextBatch(3) = %q, %v", batch, done)
+	}
+}
+
+func TestInfoLogCompaction(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+
+	var mu sync.Mutex
+	var lines []string
+	options := NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetInfoLog(func(msg string) {
+		mu.Lock()
+		lines = append(lines, msg)
+		mu.Unlock()
+	})
+	db, err := Open(dbname, options)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+	}
+	db.CompactRange(nil, nil)
+
+	// The lines may be passed a little later.
+	logged := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(strings.Join(lines, "\n"), "Level-0 table #")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !logged() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !logged() {
+		mu.Lock()
+		t.Errorf("the compaction of the memtable wasn't logged, got %q", lines)
+		mu.Unlock()
+	}
+}
+
+// tableFiles returns the table files of the database, oldest first.
+func tableFiles(dbname string) []string {
+	files, _ := filepath.Glob(filepath.Join(dbname, "*.ldb"))
+	sst, _ := filepath.Glob(filepath.Join(dbname, "*.sst"))
+	files = append(files, sst...)
+	sort.Strings(files)
+	return files
+}
+
+// corruptTableFiles overwrites the head of every table file in the database,
+// which holds its first data block, and returns how many files it touched.
+func corruptTableFiles(t testing.TB, dbname string) int {
+	files := tableFiles(dbname)
+	for _, name := range files {
+		corruptFile(t, name, 16)
+	}
+	return len(files)
+}
+
+// corruptFile overwrites 64 bytes of the file at offset.
+func corruptFile(t testing.TB, name string, offset int64) {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Unable to open %s: %v", name, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xff}, 64), offset); err != nil {
+		t.Fatalf("Unable to corrupt %s: %v", name, err)
+	}
+}
+
+func TestIteratorReset(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	db.Put(nil, []byte("a"), []byte("1"))
+	db.Put(nil, []byte("b"), []byte("2"))
+
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	CheckScan(t, "first scan", it, "a", "b")
+
+	db.Put(nil, []byte("c"), []byte("3"))
+	it.Reset(ro)
+	CheckScan(t, "reset on the same snapshot", it, "a", "b")
+
+	snap2 := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap2)
+	ro2 := NewReadOptions()
+	defer ro2.Destroy()
+	ro2.SetSnapshot(snap2)
+	it.Reset(ro2)
+	CheckScan(t, "reset on a new snapshot", it, "a", "b", "c")
+
+	db.Delete(nil, []byte("a"))
+	it.Reset(nil)
+	CheckScan(t, "reset without snapshot", it, "b", "c")
+}
+
+// CheckScan scans it from the first key and checks the keys it visits.
+func CheckScan(t *testing.T, where string, it *Iterator, keys ...string) {
+	var got []string
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		got = append(got, string(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		t.Errorf("%s, scan failed: %v", where, err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(keys) {
+		t.Errorf("%s, expected keys %v, got %v", where, keys, got)
+	}
+}
+
+func benchmarkIteratorQueries(b *testing.B, query func(db *DB, ro *ReadOptions, it *Iterator) *Iterator) {
+	dbname := tempDir(b)
+	defer deleteDBDirectory(b, dbname)
+	db := openTestDB(b, dbname)
+	defer db.Close()
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%04d", i))
+		db.Put(nil, keys[i], []byte("value"))
+	}
+
+	snap := db.GetSnapshot()
+	defer db.ReleaseSnapshot(snap)
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snap)
+
+	it := db.NewIterator(ro)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it = query(db, ro, it)
+		it.Seek(keys[i%len(keys)])
+	}
+	it.Close()
+}
+
+func benchmarkScan(b *testing.B, scan func(it *Iterator) int) {
+	dbname := tempDir(b)
+	defer deleteDBDirectory(b, dbname)
+	db := openTestDB(b, dbname)
+	defer db.Close()
+
+	const n = 1000000
+	wb := NewWriteBatch()
+	defer wb.Destroy()
+	for i := 0; i < n; i++ {
+		wb.Put([]byte(fmt.Sprintf("key%08d", i)), []byte("value"))
+		if i%10000 == 9999 {
+			db.Write(nil, wb)
+			wb.Clear()
+		}
+	}
+	db.CompactRange(nil, nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it := db.NewIterator(nil)
+		it.SeekToFirst()
+		if got := scan(it); got != n {
+			b.Fatalf("scanned %d entries, want %d", got, n)
+		}
+		it.Close()
+	}
+}
+
+func BenchmarkScanNext(b *testing.B) {
+	benchmarkScan(b, func(it *Iterator) (n int) {
+		for ; it.Valid(); it.Next() {
+			it.Key()
+			it.Value()
+			n++
+		}
+		return n
+	})
+}
+
+func BenchmarkScanNextBatch(b *testing.B) {
+	benchmarkScan(b, func(it *Iterator) (n int) {
+		for done := false; !done; {
+			var keys [][]byte
+			keys, _, done = it.NextBatch(256)
+			n += len(keys)
+		}
+		return n
+	})
+}
+
+func BenchmarkIteratorRecreate(b *testing.B) {
+	benchmarkIteratorQueries(b, func(db *DB, ro *ReadOptions, it *Iterator) *Iterator {
+		it.Close()
+		return db.NewIterator(ro)
+	})
+}
+
+func BenchmarkIteratorReset(b *testing.B) {
+	benchmarkIteratorQueries(b, func(db *DB, ro *ReadOptions, it *Iterator) *Iterator {
+		it.Reset(ro)
+		return it
+	})
+}
+
 func CheckGet(t *testing.T, where string, db *DB, roptions *ReadOptions, key, expected []byte) {
 	getValue, err := db.Get(roptions, key)
 
@@ -337,14 +4719,14 @@ func CheckIter(t *testing.T, it *Iterator, key, value []byte) {
 	}
 }
 
-func deleteDBDirectory(t *testing.T, dirPath string) {
+func deleteDBDirectory(t testing.TB, dirPath string) {
 	err := os.RemoveAll(dirPath)
 	if err != nil {
 		t.Errorf("Unable to remove database directory: %s", dirPath)
 	}
 }
 
-func tempDir(t *testing.T) string {
+func tempDir(t testing.TB) string {
 	bottom := fmt.Sprintf("goleveldb-test-%d", rand.Int())
 	path := filepath.Join(os.TempDir(), bottom)
 	deleteDBDirectory(t, path)
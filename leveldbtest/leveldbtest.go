@@ -0,0 +1,35 @@
+// Package leveldbtest provides helpers for the tests of programs using
+// goleveldb.
+//
+// LevelDB databases live in directories; the C API of LevelDB has no
+// in-memory Env to avoid that. NewTempDB opens databases in temporary
+// directories that are removed at the end of the test.
+package leveldbtest
+
+import (
+	"testing"
+
+	"github.com/chanxuehong/goleveldb"
+)
+
+// NewTempDB opens a new, empty database in a temporary directory. The
+// database is closed and the directory removed when the test and its
+// subtests complete. Open failures are fatal to the test.
+func NewTempDB(t testing.TB) *goleveldb.DB {
+	t.Helper()
+
+	options := goleveldb.NewOptions()
+	defer options.Destroy()
+	options.SetCreateIfMissing(true)
+	options.SetErrorIfExists(true)
+
+	// Cleanups run last registered first: db is closed before the
+	// directory is removed.
+	dir := t.TempDir()
+	db, err := goleveldb.Open(dir, options)
+	if err != nil {
+		t.Fatalf("leveldbtest: opening %s: %v", dir, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,24 @@
+package leveldbtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chanxuehong/goleveldb"
+)
+
+func TestNewTempDB(t *testing.T) {
+	db := NewTempDB(t)
+	if err := db.Put(nil, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	value, err := db.Get(nil, []byte("key"))
+	if err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Errorf("Get = %q, %v, want %q", value, err, "value")
+	}
+
+	other := NewTempDB(t)
+	if _, err := other.Get(nil, []byte("key")); err != goleveldb.ErrNotFound {
+		t.Errorf("Get from another temporary DB = %v, want ErrNotFound", err)
+	}
+}
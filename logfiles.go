@@ -0,0 +1,45 @@
+package goleveldb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PruneLogs removes the old info log files (LOG.old, LOG.old.*) of the
+// database but the newest keep of them, and returns how many were removed.
+// The current LOG file is never touched.
+func (db *DB) PruneLogs(keep int) (removed int, err error) {
+	names, err := filepath.Glob(filepath.Join(db.Path(), "LOG.old*"))
+	if err != nil {
+		return 0, err
+	}
+
+	type logFile struct {
+		name string
+		info os.FileInfo
+	}
+	logs := make([]logFile, 0, len(names))
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return 0, err
+		}
+		logs = append(logs, logFile{name, info})
+	}
+	// newest first
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].info.ModTime().After(logs[j].info.ModTime())
+	})
+
+	if keep < 0 {
+		keep = 0
+	}
+	for i := keep; i < len(logs); i++ {
+		if err := os.Remove(logs[i].name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
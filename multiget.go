@@ -0,0 +1,115 @@
+package goleveldb
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchGetSorted returns the values of the given keys, indexed by
+// string(key).
+//
+// Unlike calling Get for each key in the caller's order, the keys are
+// de-duplicated and read in the order of the DB's comparator, so neighbouring
+// reads tend to hit the same blocks in the block cache and the same SSTables
+// on disk. All the reads are served from one snapshot, so the returned map is
+// a consistent view of the database.
+//
+// Keys that do not exist in the database are absent from the returned map.
+// The keys slice itself is not reordered.
+//
+// If ro carries a snapshot it is used, otherwise a temporary snapshot is
+// taken for the duration of the call.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) BatchGetSorted(ro *ReadOptions, keys [][]byte) (map[string][]byte, error) {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return db.cmp(sorted[i], sorted[j]) < 0
+	})
+
+	ro, release := db.snapshotReadOptions(ro)
+	defer release()
+
+	values := make(map[string][]byte, len(sorted))
+	for i, key := range sorted {
+		if i > 0 && db.cmp(sorted[i-1], key) == 0 {
+			continue
+		}
+
+		value, err := db.Get(ro, key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[string(key)] = value
+	}
+	return values, nil
+}
+
+// MultiGet returns the values of the given keys, read from a single
+// snapshot so they are a consistent view of the database. values[i] and
+// errs[i] are the result of reading keys[i]: a key that does not exist gets
+// a nil value and ErrNotFound.
+//
+// If ro carries a snapshot it is used, otherwise a temporary snapshot is
+// taken for the duration of the call.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) MultiGet(ro *ReadOptions, keys [][]byte) (values [][]byte, errs []error) {
+	ro, release := db.snapshotReadOptions(ro)
+	defer release()
+
+	values = make([][]byte, len(keys))
+	errs = make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = db.Get(ro, key)
+	}
+	return values, errs
+}
+
+// GetMultiWorkers is the number of goroutines GetMulti reads with. Values
+// below 2 make it read serially, like MultiGet.
+var GetMultiWorkers = 8
+
+// GetMulti is like MultiGet, but reads the keys in parallel from up to
+// GetMultiWorkers goroutines, which pays off when the reads wait on disk or
+// the values are large. values[i] and errs[i] are still the result of
+// reading keys[i].
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) GetMulti(ro *ReadOptions, keys [][]byte) (values [][]byte, errs []error) {
+	workers := GetMultiWorkers
+	if workers > len(keys) {
+		workers = len(keys)
+	}
+	if workers < 2 {
+		return db.MultiGet(ro, keys)
+	}
+
+	ro, release := db.snapshotReadOptions(ro)
+	defer release()
+
+	values = make([][]byte, len(keys))
+	errs = make([]error, len(keys))
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(keys) {
+					return
+				}
+				values[i], errs[i] = db.Get(ro, keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return values, errs
+}
@@ -4,6 +4,11 @@ package goleveldb
 // #include "leveldb/c.h"
 import "C"
 
+import (
+	"runtime"
+	"time"
+)
+
 type CompressionType int
 
 // DB contents are stored in a set of blocks, each of which holds a
@@ -24,17 +29,41 @@ const (
 // program no longer needs it.
 type Options struct {
 	opt *C.leveldb_options_t
+
+	// Objects the C options point to, handed to the DB by Open to keep them
+	// from being finalized while it uses them.
+	cmp          *Comparator
+	cache        *Cache
+	filterPolicy *FilterPolicy
+	env          *Env
+	infoLog      *infoLog
+
+	// Settings handled on the Go side, copied to the DB by Open.
+	slowOpThreshold time.Duration
+	slowOpLogger    Logger
+
+	// Where the DB copies the lines of its LOG file, see SetInfoLogFile.
+	infoLogFile string
+	infoLogFunc func(msg string)
 }
 
 // NewOptions allocates a new Options object.
 func NewOptions() *Options {
-	return &Options{C.leveldb_options_create()}
+	o := &Options{opt: C.leveldb_options_create()}
+	runtime.SetFinalizer(o, finalize)
+	trackAlloc(o, "Options")
+	return o
 }
 
 // Destroy deallocates the Options, freeing its underlying C struct.
 func (o *Options) Destroy() {
+	if o.opt == nil {
+		return
+	}
+	runtime.SetFinalizer(o, nil)
 	C.leveldb_options_destroy(o.opt)
 	o.opt = nil
+	trackFree(o)
 }
 
 // Comparator used to define the order of keys in the table.
@@ -45,9 +74,12 @@ func (o *Options) Destroy() {
 // REQUIRES: The client must ensure that the comparator supplied
 // here has the same name and orders keys *exactly* the same as the
 // comparator provided to previous open calls on the same DB.
-func (o *Options) SetComparator(cmp *C.leveldb_comparator_t) {
+//
+// The Comparator must outlive the databases opened with it.
+func (o *Options) SetComparator(cmp *Comparator) {
 	if cmp != nil {
-		C.leveldb_options_set_comparator(o.opt, cmp)
+		C.leveldb_options_set_comparator(o.opt, cmp.cmp)
+		o.cmp = cmp
 	}
 }
 
@@ -56,8 +88,17 @@ func (o *Options) SetComparator(cmp *C.leveldb_comparator_t) {
 //  there is one of them applied at any time.
 //
 //  Default: nil
-func (o *Options) SetFilterPolicy(fp *C.leveldb_filterpolicy_t) {
-	C.leveldb_options_set_filter_policy(o.opt, fp)
+//
+// The DB keeps a reference to the FilterPolicy, so it is not finalized
+// while the DB is open, but it must not be destroyed before the databases
+// opened with it are closed.
+func (o *Options) SetFilterPolicy(fp *FilterPolicy) {
+	if fp == nil {
+		C.leveldb_options_set_filter_policy(o.opt, nil)
+	} else {
+		C.leveldb_options_set_filter_policy(o.opt, fp.fp)
+	}
+	o.filterPolicy = fp
 }
 
 // Use the specified filter policy to reduce disk reads.
@@ -66,20 +107,21 @@ func (o *Options) SetFilterPolicy(fp *C.leveldb_filterpolicy_t) {
 //
 //  Default: nil
 func (o *Options) SetBloomFilterPolicy(fp *FilterPolicy) {
-	if fp == nil {
-		C.leveldb_options_set_filter_policy(o.opt, nil)
-	} else {
-		C.leveldb_options_set_filter_policy(o.opt, fp.fp)
-	}
+	o.SetFilterPolicy(fp)
 }
 
-// If not non-nil, use the specified object to interact with the environment,
+// If non-nil, use the specified object to interact with the environment,
 // e.g. to read/write files, schedule background work, etc.
 //
-//  Default: C.leveldb_create_default_env()
-func (o *Options) SetEnv(env *C.leveldb_env_t) {
+//  Default: the default Env, see NewDefaultEnv
+//
+// The DB keeps a reference to the Env, so it is not finalized while the DB
+// is open, but it must not be destroyed before the databases opened with it
+// are closed.
+func (o *Options) SetEnv(env *Env) {
 	if env != nil {
-		C.leveldb_options_set_env(o.opt, env)
+		C.leveldb_options_set_env(o.opt, env.env)
+		o.env = env
 	}
 }
 
@@ -108,15 +150,6 @@ func (o *Options) SetParanoidChecks(b bool) {
 	C.leveldb_options_set_paranoid_checks(o.opt, bool2uchar(b))
 }
 
-// Any internal progress/error information generated by the db will
-// be written to info_log if it is non-nil, or to a file stored
-// in the same directory as the DB contents if info_log is nil.
-//
-//  Default: nil
-func (o *Options) SetInfoLog(log *C.leveldb_logger_t) {
-	C.leveldb_options_set_info_log(o.opt, log)
-}
-
 // Amount of data to build up in memory (backed by an unsorted log
 // on disk) before converting to a sorted on-disk file.
 //
@@ -143,12 +176,40 @@ func (o *Options) SetMaxOpenFiles(n int) {
 // If non-nil, use the specified cache for blocks.
 //
 //  Default: leveldb will automatically create and use an 8MB internal cache.
+//
+// The same Cache may be set on the Options of several databases, to bound
+// the memory their blocks take in all: they then share its capacity.
+//
+// The DB keeps a reference to the Cache, so it is not finalized while the
+// DB is open, but it must not be destroyed before all the databases opened
+// with it are closed.
 func (o *Options) SetCache(cache *Cache) {
 	if cache != nil {
 		C.leveldb_options_set_cache(o.opt, cache.cache)
+		o.cache = cache
 	}
 }
 
+// SetBlockCache sets the cache of the blocks read from the table files, the
+// only cache of LevelDB that can be configured: it is SetCache, but a nil
+// cache disables block caching rather than being ignored.
+//
+// LevelDB always goes through a block cache, so "disabled" means a Cache of
+// zero capacity, created for o, from which every block is evicted as soon as
+// it is no longer used. To keep caching but not for some reads, see
+// ReadOptions.SetFillCache.
+//
+//  Default: leveldb will automatically create and use an 8MB internal cache.
+func (o *Options) SetBlockCache(cache *Cache) {
+	if cache == nil {
+		// Not tracked as a leak: nobody but o and its DBs can destroy it,
+		// it is finalized once they are gone.
+		cache = &Cache{cache: C.leveldb_cache_create_lru(0)}
+		runtime.SetFinalizer(cache, finalize)
+	}
+	o.SetCache(cache)
+}
+
 // Approximate size of user data packed per block.  Note that the
 // block size specified here corresponds to uncompressed data.  The
 // actual size of the unit read from disk may be smaller if
@@ -182,6 +243,28 @@ func (o *Options) SetBlockRestartInterval(n int) {
 // worth switching to SnappyCompression.  Even if the input data is
 // incompressible, the SnappyCompression implementation will
 // efficiently detect that and will switch to uncompressed mode.
+//
+// Values other than NoCompression and SnappyCompression are ignored.
 func (o *Options) SetCompression(t CompressionType) {
-	C.leveldb_options_set_compression(o.opt, C.int(t))
+	switch t {
+	case NoCompression, SnappyCompression:
+		C.leveldb_options_set_compression(o.opt, C.int(t))
+	}
+}
+
+// If positive, Get, Put, Delete and Write calls taking longer than d are
+// logged to the slow operation logger, along with the beginning of the key
+// involved. Timing costs a couple of time.Now calls per operation; nothing
+// is done when it is disabled.
+//
+//  Default: 0 (disabled)
+func (o *Options) SetSlowOpThreshold(d time.Duration) {
+	o.slowOpThreshold = d
+}
+
+// Where slow operations are logged, see SetSlowOpThreshold.
+//
+//  Default: nil, the standard library's log package
+func (o *Options) SetSlowOpLogger(logger Logger) {
+	o.slowOpLogger = logger
 }
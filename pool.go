@@ -0,0 +1,126 @@
+package goleveldb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Pools of ReadOptions and WriteOptions with the default settings. The
+// pooled options are not tracked by EnableLeakTracking: the pool owns them,
+// and the ones it drops are freed by their finalizer.
+var (
+	readOptionsPool = sync.Pool{New: func() interface{} {
+		ro := NewReadOptions()
+		trackFree(ro)
+		return ro
+	}}
+	writeOptionsPool = sync.Pool{New: func() interface{} {
+		wo := NewWriteOptions()
+		trackFree(wo)
+		return wo
+	}}
+)
+
+// GetReadOptions returns a ReadOptions with the default settings from a
+// pool shared by the package, saving the allocation of a new one per read.
+//
+// Give it back with PutReadOptions when done, and don't call its Destroy.
+func GetReadOptions() *ReadOptions {
+	ro := readOptionsPool.Get().(*ReadOptions)
+	if ro.opt == nil {
+		// destroyed while pooled, despite the documentation
+		ro = NewReadOptions()
+		trackFree(ro)
+	}
+	atomic.StoreInt32(&ro.pooled, 0)
+	return ro
+}
+
+// PutReadOptions resets ro to the default settings and returns it to the
+// pool of GetReadOptions. ro must not be used afterwards, nor put again: once
+// GetReadOptions hands it out again, a second Put would give it to two
+// users. Putting a ReadOptions that was destroyed does nothing.
+func PutReadOptions(ro *ReadOptions) {
+	// pooled only catches a Put repeated before the next Get.
+	if ro == nil || ro.opt == nil || !atomic.CompareAndSwapInt32(&ro.pooled, 0, 1) {
+		return
+	}
+	// Reset before pooling so as not to keep the snapshot reachable.
+	if ro.snap != nil {
+		ro.SetSnapshot(nil)
+	}
+	if !ro.fillCache {
+		ro.SetFillCache(true)
+	}
+	if ro.verifyChecksums {
+		ro.SetVerifyChecksums(false)
+	}
+	readOptionsPool.Put(ro)
+}
+
+// GetWriteOptions returns a WriteOptions with the default settings from a
+// pool shared by the package, saving the allocation of a new one per write.
+//
+// Give it back with PutWriteOptions when done, and don't call its Destroy.
+func GetWriteOptions() *WriteOptions {
+	wo := writeOptionsPool.Get().(*WriteOptions)
+	if wo.opt == nil {
+		// destroyed while pooled, despite the documentation
+		wo = NewWriteOptions()
+		trackFree(wo)
+	}
+	atomic.StoreInt32(&wo.pooled, 0)
+	return wo
+}
+
+// PutWriteOptions resets wo to the default settings and returns it to the
+// pool of GetWriteOptions. wo must not be used afterwards, nor put again:
+// once GetWriteOptions hands it out again, a second Put would give it to
+// two users. Putting a WriteOptions that was destroyed does nothing.
+func PutWriteOptions(wo *WriteOptions) {
+	// pooled only catches a Put repeated before the next Get.
+	if wo == nil || wo.opt == nil || !atomic.CompareAndSwapInt32(&wo.pooled, 0, 1) {
+		return
+	}
+	if wo.sync {
+		wo.SetSync(false)
+	}
+	writeOptionsPool.Put(wo)
+}
+
+// A WriteBatchPool keeps WriteBatches for reuse, saving the allocation of a
+// new C batch each time one is needed. The zero value is ready to use, and a
+// WriteBatchPool is safe for concurrent use.
+//
+// Like the pooled options, the pooled batches are not tracked by
+// EnableLeakTracking, and the ones the pool drops are freed by their
+// finalizer.
+type WriteBatchPool struct {
+	pool sync.Pool
+}
+
+// Get returns an empty WriteBatch of the pool, or a new one if the pool is
+// empty.
+//
+// Give it back with Put when done, and don't call its Destroy.
+func (p *WriteBatchPool) Get() *WriteBatch {
+	w, _ := p.pool.Get().(*WriteBatch)
+	if w == nil || w.wbatch == nil {
+		w = NewWriteBatch()
+		trackFree(w)
+	}
+	atomic.StoreInt32(&w.pooled, 0)
+	return w
+}
+
+// Put clears w and returns it to the pool. w must not be used afterwards,
+// nor put again: once Get hands it out again, a second Put would give it to
+// two users. Putting a WriteBatch that was destroyed does nothing.
+func (p *WriteBatchPool) Put(w *WriteBatch) {
+	// pooled only catches a Put repeated before the next Get.
+	if w == nil || w.wbatch == nil || !atomic.CompareAndSwapInt32(&w.pooled, 0, 1) {
+		return
+	}
+	w.Clear()
+	p.pool.Put(w)
+}
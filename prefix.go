@@ -0,0 +1,197 @@
+package goleveldb
+
+import (
+	"bytes"
+	"errors"
+)
+
+// NewPrefixIterator returns an Iterator over the keys starting with prefix,
+// positioned at the first of them.
+//
+// The iterator doesn't go past the keys with the prefix: Valid returns false
+// once it moves to a key without it, and SeekToFirst and SeekToLast move to
+// the first and last keys with the prefix. Like the other prefix helpers, it
+// expects the keys with the prefix to be adjacent in the order of the DB, as
+// they are in the default bytewise order.
+//
+// With a custom comparator, where the keys with the prefix can't be sought
+// directly, SeekToFirst and SeekToLast walk from the first or last key of
+// the database to the keys with the prefix.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewPrefixIterator(ro *ReadOptions, prefix []byte) *Iterator {
+	it := db.newPrefixIterator(ro, prefix)
+	it.SeekToFirst()
+	return it
+}
+
+// NewReversePrefixIterator is NewPrefixIterator, but returns the iterator
+// positioned at the last key starting with prefix, to walk them in
+// descending order with Prev. Valid returns false once Prev moves before the
+// first key with the prefix.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewReversePrefixIterator(ro *ReadOptions, prefix []byte) *Iterator {
+	it := db.newPrefixIterator(ro, prefix)
+	// SeekToLast seeks to the successor of prefix and steps back, or moves
+	// to the last key of the database if prefix has no successor.
+	it.SeekToLast()
+	return it
+}
+
+func (db *DB) newPrefixIterator(ro *ReadOptions, prefix []byte) *Iterator {
+	prefix = append([]byte{}, prefix...)
+	it := db.NewIterator(ro)
+	it.prefix = prefix
+	if !db.bytewise {
+		// The bounds below are in bytewise order: only the prefix is
+		// checked, see seekToFirst and seekToLast.
+		return it
+	}
+	it.start = prefix
+	// Without a successor, all the keys after prefix start with it.
+	it.limit = prefixSuccessor(prefix)
+	return it
+}
+
+// prefixBounds returns the first and last keys starting with prefix, in the
+// order of the DB, for the prefix helpers that can't use prefixSuccessor
+// with a custom comparator. ok is false if there is no such key.
+func (db *DB) prefixBounds(prefix []byte) (first, last []byte, ok bool) {
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetFillCache(false)
+	it := db.NewPrefixIterator(ro, prefix)
+	defer it.Close()
+
+	if !it.Valid() {
+		return nil, nil, false
+	}
+	first = it.Key()
+	it.SeekToLast()
+	if !it.Valid() {
+		return nil, nil, false
+	}
+	return first, it.Key(), true
+}
+
+// prefixSuccessor returns the smallest key greater than all the keys
+// starting with prefix in bytewise order, or nil if there is none (prefix is
+// empty or all 0xff).
+func prefixSuccessor(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			succ := append([]byte(nil), prefix[:i+1]...)
+			succ[i]++
+			return succ
+		}
+	}
+	return nil
+}
+
+// CompactPrefix compacts the keys starting with prefix, see CompactRange,
+// e.g. to reclaim the space of the keys just deleted under a prefix. Like
+// the other prefix helpers, it expects the keys with the prefix to be
+// adjacent in the order of the DB.
+//
+// With a custom comparator, the keys with the prefix can't be located once
+// deleted: the whole database is compacted.
+func (db *DB) CompactPrefix(prefix []byte) {
+	if !db.bytewise {
+		db.CompactRange(nil, nil)
+		return
+	}
+	// Without a successor, all the keys after prefix start with it: a nil
+	// end compacts up to the end of the database.
+	db.CompactRange(prefix, prefixSuccessor(prefix))
+}
+
+// replaceBatchSize is how many updates ReplacePrefix writes per WriteBatch.
+const replaceBatchSize = 1000
+
+// ReplacePrefix replaces all the entries whose key starts with prefix by
+// entries, whose keys must all start with prefix.
+//
+// The existing keys that are not part of entries are deleted first, then
+// entries are written, in WriteBatches of up to 1000 updates. Each batch is
+// applied atomically, but the replacement as a whole is atomic only if it
+// fits in a single batch: readers may otherwise see some of the old keys
+// gone before the new entries show up.
+//
+// Set the WriteOptions default if wo == nil
+func (db *DB) ReplacePrefix(wo *WriteOptions, prefix []byte, entries []KV) error {
+	keep := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if !bytes.HasPrefix(e.Key, prefix) {
+			return errors.New("goleveldb: ReplacePrefix entry key outside the prefix")
+		}
+		keep[string(e.Key)] = true
+	}
+
+	ro, release := db.snapshotReadOptions(nil)
+	defer release()
+	it := db.NewIterator(ro)
+	defer it.Close()
+	w := newBatchWriter(db, wo, replaceBatchSize)
+	defer w.destroy()
+
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		if keep[string(key)] {
+			continue
+		}
+		if err := w.delete(key); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := w.put(e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+// PrefixStat describes the entries sharing a key prefix.
+type PrefixStat struct {
+	Count      int   // number of keys
+	ValueBytes int64 // total size of their values
+}
+
+// AnalyzePrefixes scans the whole database and groups the keys by their
+// first depth bytes, to show how the key space is distributed. Keys shorter
+// than depth are grouped under their full key. depth must be at least 1.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) AnalyzePrefixes(ro *ReadOptions, depth int) (map[string]PrefixStat, error) {
+	if depth < 1 {
+		return nil, errors.New("goleveldb: prefix depth below 1")
+	}
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	stats := make(map[string]PrefixStat)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key := it.Key()
+		if len(key) > depth {
+			key = key[:depth]
+		}
+		_, vlen := it.entrySize()
+
+		stat := stats[string(key)]
+		stat.Count++
+		stat.ValueBytes += int64(vlen)
+		stats[string(key)] = stat
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
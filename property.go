@@ -0,0 +1,89 @@
+package goleveldb
+
+import (
+	"errors"
+	"strconv"
+)
+
+// numLevels is the number of levels of a LevelDB database.
+const numLevels = 7
+
+// ErrPropertyUnsupported means that the linked LevelDB doesn't know the
+// property a typed property getter relies on.
+var ErrPropertyUnsupported = errors.New("goleveldb: property not supported by this LevelDB")
+
+// ApproximateMemoryUsage returns the approximate number of bytes of memory
+// in use by the DB: memtables, table readers and block cache.
+//
+// It relies on the "leveldb.approximate-memory-usage" property, which
+// appeared in LevelDB 1.17; ErrPropertyUnsupported is returned with older
+// versions.
+func (db *DB) ApproximateMemoryUsage() (uint64, error) {
+	if db.db == nil {
+		return 0, ErrClosed
+	}
+	value := db.GetProperty("leveldb.approximate-memory-usage")
+	if value == "" {
+		return 0, ErrPropertyUnsupported
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// NumFilesAtLevel returns the number of table files at level, from the
+// "leveldb.num-files-at-level<N>" property. Levels go from 0 to 6.
+func (db *DB) NumFilesAtLevel(level int) (int, error) {
+	if level < 0 {
+		return 0, errors.New("goleveldb: negative level " + strconv.Itoa(level))
+	}
+	if db.db == nil {
+		return 0, ErrClosed
+	}
+	value := db.GetProperty("leveldb.num-files-at-level" + strconv.Itoa(level))
+	if value == "" {
+		return 0, errors.New("goleveldb: no file count for level " + strconv.Itoa(level))
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("goleveldb: malformed file count for level " + strconv.Itoa(level) + ": " + strconv.Quote(value))
+	}
+	return n, nil
+}
+
+// LevelFileCounts returns the number of table files at each of the levels 0
+// to 6, see NumFilesAtLevel.
+func (db *DB) LevelFileCounts() ([]int, error) {
+	counts := make([]int, numLevels)
+	for level := range counts {
+		n, err := db.NumFilesAtLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		counts[level] = n
+	}
+	return counts, nil
+}
+
+// StatsText returns the "leveldb.stats" property as is, a table of the files
+// and compactions at each level; Stats returns it parsed.
+func (db *DB) StatsText() (string, error) {
+	return db.textProperty("leveldb.stats")
+}
+
+// SSTables returns the "leveldb.sstables" property, a multi-line description
+// of the table files at each level with their number, size and key range.
+func (db *DB) SSTables() (string, error) {
+	return db.textProperty("leveldb.sstables")
+}
+
+// textProperty returns the value of a property whose value is never empty
+// when the linked LevelDB knows it.
+func (db *DB) textProperty(name string) (string, error) {
+	if db.db == nil {
+		return "", ErrClosed
+	}
+	value := db.GetProperty(name)
+	if value == "" {
+		return "", ErrPropertyUnsupported
+	}
+	return value, nil
+}
@@ -0,0 +1,117 @@
+package goleveldb
+
+import (
+	"time"
+)
+
+// RateLimitedIterator is an Iterator that limits the rate at which it reads
+// the database, so that bulk scans don't starve foreground traffic of disk
+// bandwidth.
+//
+// Each positioning method accounts for the size of the key and value it
+// lands on, and NextBatch for the entries it returns, and sleeps as needed
+// to keep the scan under the configured number of bytes per second. Unused
+// budget accumulates for at most one second.
+type RateLimitedIterator struct {
+	*Iterator
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimitedIterator returns an Iterator over the database that reads at
+// most bytesPerSec bytes of keys and values per second. A bytesPerSec of 0
+// or less means no limit.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) NewRateLimitedIterator(ro *ReadOptions, bytesPerSec int) *RateLimitedIterator {
+	return &RateLimitedIterator{
+		Iterator: db.NewIterator(ro),
+		rate:     float64(bytesPerSec),
+		last:     time.Now(),
+	}
+}
+
+// Next is Iterator.Next, throttled.
+func (it *RateLimitedIterator) Next() {
+	it.Iterator.Next()
+	it.throttle()
+}
+
+// Prev is Iterator.Prev, throttled.
+func (it *RateLimitedIterator) Prev() {
+	it.Iterator.Prev()
+	it.throttle()
+}
+
+// SeekToFirst is Iterator.SeekToFirst, throttled.
+func (it *RateLimitedIterator) SeekToFirst() {
+	it.Iterator.SeekToFirst()
+	it.throttle()
+}
+
+// SeekToLast is Iterator.SeekToLast, throttled.
+func (it *RateLimitedIterator) SeekToLast() {
+	it.Iterator.SeekToLast()
+	it.throttle()
+}
+
+// Seek is Iterator.Seek, throttled.
+func (it *RateLimitedIterator) Seek(key []byte) {
+	it.Iterator.Seek(key)
+	it.throttle()
+}
+
+// SeekChecked is Iterator.SeekChecked, throttled.
+func (it *RateLimitedIterator) SeekChecked(key []byte) error {
+	it.Seek(key)
+	return it.Error()
+}
+
+// SeekForPrev is Iterator.SeekForPrev, throttled.
+func (it *RateLimitedIterator) SeekForPrev(key []byte) {
+	it.Iterator.SeekForPrev(key)
+	it.throttle()
+}
+
+// NextBatch is Iterator.NextBatch, throttled: the entries returned are
+// accounted for once they are read.
+func (it *RateLimitedIterator) NextBatch(max int) (keys, values [][]byte, done bool) {
+	keys, values, done = it.Iterator.NextBatch(max)
+	n := 0
+	for i := range keys {
+		n += len(keys[i]) + len(values[i])
+	}
+	it.charge(n)
+	return keys, values, done
+}
+
+// throttle charges the current entry to the token bucket, sleeping off any
+// debt.
+func (it *RateLimitedIterator) throttle() {
+	if it.rate <= 0 || !it.Valid() {
+		return
+	}
+	klen, vlen := it.entrySize()
+	it.charge(klen + vlen)
+}
+
+// charge takes n bytes from the token bucket, sleeping off any debt.
+func (it *RateLimitedIterator) charge(n int) {
+	if it.rate <= 0 || n == 0 {
+		return
+	}
+
+	now := time.Now()
+	it.tokens += now.Sub(it.last).Seconds() * it.rate
+	if it.tokens > it.rate {
+		it.tokens = it.rate
+	}
+	it.last = now
+
+	it.tokens -= float64(n)
+	if it.tokens < 0 {
+		// The time slept is credited back on the next call.
+		time.Sleep(time.Duration(-it.tokens / it.rate * float64(time.Second)))
+	}
+}
@@ -4,23 +4,45 @@ package goleveldb
 // #include "leveldb/c.h"
 import "C"
 
+import (
+	"runtime"
+)
+
 // Options that control read operations
 //
 // To prevent memory leaks, Destroy must called on a ReadOptions when the
 // program no longer needs it.
 type ReadOptions struct {
 	opt *C.leveldb_readoptions_t
+
+	// The C API has no getters, so the settings are mirrored here.
+	verifyChecksums bool
+	fillCache       bool
+	snap            *Snapshot
+
+	pooled int32 // 1 while in the pool of GetReadOptions
 }
 
 // NewReadOptions allocates a new ReadOptions object.
 func NewReadOptions() *ReadOptions {
-	return &ReadOptions{C.leveldb_readoptions_create()}
+	ro := &ReadOptions{
+		opt:       C.leveldb_readoptions_create(),
+		fillCache: true,
+	}
+	runtime.SetFinalizer(ro, finalize)
+	trackAlloc(ro, "ReadOptions")
+	return ro
 }
 
 // Destroy deallocates the ReadOptions, freeing its underlying C struct.
 func (ro *ReadOptions) Destroy() {
+	if ro.opt == nil {
+		return
+	}
+	runtime.SetFinalizer(ro, nil)
 	C.leveldb_readoptions_destroy(ro.opt)
 	ro.opt = nil
+	trackFree(ro)
 }
 
 // If true, all data read from underlying storage will be
@@ -29,14 +51,19 @@ func (ro *ReadOptions) Destroy() {
 //  Default: false
 func (ro *ReadOptions) SetVerifyChecksums(b bool) {
 	C.leveldb_readoptions_set_verify_checksums(ro.opt, bool2uchar(b))
+	ro.verifyChecksums = b
 }
 
 // Should the data read for this iteration be cached in memory?
 // Callers may wish to set this field to false for bulk scans.
 //
+// The cache is still looked up when this is false, LevelDB has no way to
+// bypass it entirely; but the blocks read don't evict the cached ones.
+//
 //  Default: true
 func (ro *ReadOptions) SetFillCache(b bool) {
 	C.leveldb_readoptions_set_fill_cache(ro.opt, bool2uchar(b))
+	ro.fillCache = b
 }
 
 // If "snapshot" is non-nil, read as of the supplied snapshot
@@ -44,6 +71,9 @@ func (ro *ReadOptions) SetFillCache(b bool) {
 // not have been released).  If "snapshot" is nil, use an impliicit
 // snapshot of the state at the beginning of this read operation.
 //
+// The reads with a snapshot released before or after it is set fail with
+// ErrSnapshotReleased.
+//
 //  Default: nil
 func (ro *ReadOptions) SetSnapshot(snap *Snapshot) {
 	if snap == nil {
@@ -51,4 +81,41 @@ func (ro *ReadOptions) SetSnapshot(snap *Snapshot) {
 	} else {
 		C.leveldb_readoptions_set_snapshot(ro.opt, snap.snap)
 	}
+	ro.snap = snap
+}
+
+// SetSnapshotChecked is SetSnapshot, but returns ErrSnapshotReleased and
+// leaves ro unchanged if snap has already been released.
+func (ro *ReadOptions) SetSnapshotChecked(snap *Snapshot) error {
+	if snap != nil && snap.Released() {
+		return ErrSnapshotReleased
+	}
+	ro.SetSnapshot(snap)
+	return nil
+}
+
+// VerifyChecksums returns the setting of SetVerifyChecksums.
+func (ro *ReadOptions) VerifyChecksums() bool {
+	return ro.verifyChecksums
+}
+
+// FillCache returns the setting of SetFillCache.
+func (ro *ReadOptions) FillCache() bool {
+	return ro.fillCache
+}
+
+// Snapshot returns the snapshot set with SetSnapshot, nil if there is none.
+func (ro *ReadOptions) Snapshot() *Snapshot {
+	return ro.snap
+}
+
+// Clone returns a new ReadOptions with the same settings as ro, to derive
+// options from a base without modifying it. The clone must be destroyed
+// separately.
+func (ro *ReadOptions) Clone() *ReadOptions {
+	c := NewReadOptions()
+	c.SetVerifyChecksums(ro.verifyChecksums)
+	c.SetFillCache(ro.fillCache)
+	c.SetSnapshot(ro.snap)
+	return c
 }
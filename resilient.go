@@ -0,0 +1,183 @@
+package goleveldb
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Logger is used to report events worth an operator's attention.
+// *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// isCorruption reports whether err is a LevelDB corruption status.
+func isCorruption(err error) bool {
+	return errors.Is(err, ErrCorruption)
+}
+
+// ResilientDB wraps a DB and recovers from corruption by itself.
+//
+// When an operation fails with a corruption error, the database is closed,
+// repaired with RepairDatabase and reopened, then the operation is retried
+// once. A repair is attempted at most once per cooldown period to avoid
+// repair loops on corruption that can't be fixed; within the cooldown the
+// error is returned as is. When a reopen fails, the operations return its
+// error until the next repair, attempted after the cooldown.
+//
+// The Options given to OpenResilient are used for every reopen, so they
+// must outlive the ResilientDB and must not set ErrorIfExists.
+//
+// A ResilientDB is safe for concurrent use. Operations wait while a repair
+// is in progress.
+type ResilientDB struct {
+	dbname   string
+	opt      *Options
+	cooldown time.Duration
+
+	mu         sync.RWMutex
+	db         *DB
+	err        error // set when a reopen fails, db is nil then
+	closed     bool
+	lastRepair time.Time
+	logger     Logger
+}
+
+// OpenResilient opens the database with the specified "dbname" and returns
+// a ResilientDB wrapping it.
+//
+// ResilientDB.Close() should called when it is no longer needed.
+func OpenResilient(dbname string, opt *Options, cooldown time.Duration) (*ResilientDB, error) {
+	db, err := Open(dbname, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &ResilientDB{
+		dbname:   dbname,
+		opt:      opt,
+		cooldown: cooldown,
+		db:       db,
+		logger:   stdLogger{},
+	}, nil
+}
+
+// SetLogger sets where repairs are reported.
+//
+//  Default: the standard library's log package
+func (r *ResilientDB) SetLogger(logger Logger) {
+	r.mu.Lock()
+	r.logger = logger
+	r.mu.Unlock()
+}
+
+// Close closes the underlying DB.
+func (r *ResilientDB) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.db != nil {
+		r.db.Close()
+		r.db = nil
+	}
+	r.err = errors.New("goleveldb: ResilientDB is closed")
+	r.closed = true
+}
+
+// Get is DB.Get with recovery from corruption.
+func (r *ResilientDB) Get(ro *ReadOptions, key []byte) (value []byte, err error) {
+	err = r.do(func(db *DB) error {
+		value, err = db.Get(ro, key)
+		return err
+	})
+	return
+}
+
+// Put is DB.Put with recovery from corruption.
+func (r *ResilientDB) Put(wo *WriteOptions, key, value []byte) error {
+	return r.do(func(db *DB) error {
+		return db.Put(wo, key, value)
+	})
+}
+
+// Delete is DB.Delete with recovery from corruption.
+func (r *ResilientDB) Delete(wo *WriteOptions, key []byte) error {
+	return r.do(func(db *DB) error {
+		return db.Delete(wo, key)
+	})
+}
+
+// Write is DB.Write with recovery from corruption.
+func (r *ResilientDB) Write(wo *WriteOptions, wb *WriteBatch) error {
+	return r.do(func(db *DB) error {
+		return db.Write(wo, wb)
+	})
+}
+
+// do runs op against the current DB, repairing and retrying once if it
+// fails with a corruption error, or if the last reopen failed.
+func (r *ResilientDB) do(op func(db *DB) error) error {
+	db, err := r.run(op)
+	if err == nil || db != nil && !isCorruption(err) || !r.repair(db, err) {
+		return err
+	}
+	_, err = r.run(op)
+	return err
+}
+
+func (r *ResilientDB) run(op func(db *DB) error) (*DB, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.db == nil {
+		return nil, r.err
+	}
+	return r.db, op(r.db)
+}
+
+// repair repairs and reopens the database after db failed with cause, or
+// after the last reopen failed if db is nil. It reports whether the
+// operation is worth retrying.
+func (r *ResilientDB) repair(db *DB, cause error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return false
+	}
+	if r.db != db {
+		// Someone else repaired (or closed) the database meanwhile.
+		return r.db != nil
+	}
+	if !r.lastRepair.IsZero() && time.Since(r.lastRepair) < r.cooldown {
+		r.logger.Printf("goleveldb: %s: %v (repair skipped, last repair %v ago)",
+			r.dbname, cause, time.Since(r.lastRepair))
+		return false
+	}
+	r.lastRepair = time.Now()
+
+	r.logger.Printf("goleveldb: %s: %v, repairing", r.dbname, cause)
+	if r.db != nil {
+		r.db.Close()
+		r.db = nil
+	}
+	if err := RepairDatabase(r.dbname, r.opt); err != nil {
+		r.logger.Printf("goleveldb: %s: repair failed: %v", r.dbname, err)
+	}
+	db, err := Open(r.dbname, r.opt)
+	if err != nil {
+		r.logger.Printf("goleveldb: %s: reopen after repair failed: %v", r.dbname, err)
+		r.err = err
+		return false
+	}
+	r.logger.Printf("goleveldb: %s: repaired and reopened", r.dbname)
+	r.db = db
+	return true
+}
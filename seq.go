@@ -0,0 +1,70 @@
+//go:build go1.23
+
+package goleveldb
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the entries of the database, in order, for
+// use with range:
+//
+// 	for key, value := range db.All(nil) {
+// 		...
+// 	}
+//
+// The keys and values are copies and may be retained. The underlying
+// Iterator is closed when the loop ends, including when it is left early.
+// Errors stop the iteration silently, use AllErr to get them.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) All(ro *ReadOptions) iter.Seq2[[]byte, []byte] {
+	seq, _ := db.AllErr(ro)
+	return seq
+}
+
+// AllErr is like All, and also returns a function reporting the error that
+// ended the last iteration, if any.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) AllErr(ro *ReadOptions) (iter.Seq2[[]byte, []byte], func() error) {
+	return db.RangeErr(ro, nil, nil)
+}
+
+// Range is like All, restricted to the keys in [start, limit). A nil start
+// or limit leaves the range unbounded on that side.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) Range(ro *ReadOptions, start, limit []byte) iter.Seq2[[]byte, []byte] {
+	seq, _ := db.RangeErr(ro, start, limit)
+	return seq
+}
+
+// RangeErr is like Range, and also returns a function reporting the error
+// that ended the last iteration, if any.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) RangeErr(ro *ReadOptions, start, limit []byte) (iter.Seq2[[]byte, []byte], func() error) {
+	var err error
+	seq := func(yield func(key, value []byte) bool) {
+		it := db.NewIterator(ro)
+		defer it.Close()
+
+		if start == nil {
+			it.SeekToFirst()
+		} else {
+			it.Seek(start)
+		}
+		for ; it.Valid(); it.Next() {
+			key := it.Key()
+			if limit != nil && db.cmp(key, limit) >= 0 {
+				break
+			}
+			if !yield(key, it.Value()) {
+				return
+			}
+		}
+		err = it.Error()
+	}
+	return seq, func() error { return err }
+}
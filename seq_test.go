@@ -0,0 +1,82 @@
+//go:build go1.23
+
+package goleveldb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAllAndRange(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	defer db.Close()
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		db.Put(nil, []byte(k), []byte(strings.ToUpper(k)))
+	}
+
+	var got []string
+	for key, value := range db.All(nil) {
+		got = append(got, string(key)+"="+string(value))
+	}
+	if s := strings.Join(got, ","); s != "a=A,b=B,c=C,d=D,e=E" {
+		t.Errorf("All = %s", s)
+	}
+
+	got = got[:0]
+	for key := range db.Range(nil, []byte("b"), []byte("d")) {
+		got = append(got, string(key))
+	}
+	if s := strings.Join(got, ","); s != "b,c" {
+		t.Errorf("Range(b, d) = %s, want b,c", s)
+	}
+
+	// breaking out of the loop closes the iterator
+	EnableLeakTracking(true)
+	defer EnableLeakTracking(false)
+	seq, errf := db.AllErr(nil)
+	n := 0
+	for range seq {
+		if n++; n == 2 {
+			break
+		}
+	}
+	if leaked := ReportLeaks(io.Discard); leaked != 0 {
+		t.Errorf("%d resources leaked after breaking out of the loop", leaked)
+	}
+	if err := errf(); err != nil {
+		t.Errorf("AllErr error = %v", err)
+	}
+}
+
+func TestAllErr(t *testing.T) {
+	dbname := tempDir(t)
+	defer deleteDBDirectory(t, dbname)
+	db := openTestDB(t, dbname)
+	for i := 0; i < 1000; i++ {
+		db.Put(nil, []byte(fmt.Sprintf("key%04d", i)), []byte("value"))
+	}
+	db.CompactRange(nil, nil)
+	db.Close()
+	corruptTableFiles(t, dbname)
+
+	db, err := Open(dbname, nil)
+	if err != nil {
+		t.Fatalf("Unable to reopen db: %v", err)
+	}
+	defer db.Close()
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+
+	seq, errf := db.AllErr(ro)
+	for range seq {
+	}
+	if err := errf(); !isCorruption(err) {
+		t.Errorf("AllErr error = %v, want a corruption", err)
+	}
+}
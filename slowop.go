@@ -0,0 +1,31 @@
+package goleveldb
+
+import (
+	"time"
+)
+
+// timeNow is time.Now, replaceable by tests.
+var timeNow = time.Now
+
+// slowOpKeyPrefix is how much of a key is logged with a slow operation.
+const slowOpKeyPrefix = 32
+
+// logSlowOp logs op if it took longer than the slow operation threshold.
+// key is nil for operations that aren't about a single key.
+func (db *DB) logSlowOp(op string, key []byte, start time.Time) {
+	d := timeNow().Sub(start)
+	if d < db.slowOpThreshold {
+		return
+	}
+
+	if key == nil {
+		db.slowOpLogger.Printf("goleveldb: slow %s took %v", op, d)
+		return
+	}
+	if len(key) > slowOpKeyPrefix {
+		db.slowOpLogger.Printf("goleveldb: slow %s of key %q... (%d bytes) took %v",
+			op, key[:slowOpKeyPrefix], len(key), d)
+		return
+	}
+	db.slowOpLogger.Printf("goleveldb: slow %s of key %q took %v", op, key, d)
+}
@@ -0,0 +1,238 @@
+package goleveldb
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// DBStats is a summary of the state of a DB, see DB.StatsJSON.
+type DBStats struct {
+	Version         string       `json:"version"`
+	LevelFiles      []int        `json:"level_files,omitempty"`
+	TotalFiles      int          `json:"total_files"`
+	ApproximateSize uint64       `json:"approximate_size"`
+	Snapshots       int          `json:"snapshots"`
+	Levels          []LevelStats `json:"levels,omitempty"` // see DB.Stats
+}
+
+// StatsJSON returns a DBStats describing the database, marshaled to JSON,
+// ready to be served by a monitoring endpoint.
+//
+// Information the linked LevelDB can't provide is left out or zero rather
+// than failing the whole call.
+func (db *DB) StatsJSON() ([]byte, error) {
+	stats := DBStats{
+		Version:         strconv.Itoa(db.MajorVersion()) + "." + strconv.Itoa(db.MinorVersion()),
+		ApproximateSize: db.Size(),
+		Snapshots:       db.SnapshotCount(),
+	}
+
+	if levels, err := db.LevelFileCounts(); err == nil {
+		for _, n := range levels {
+			stats.TotalFiles += n
+		}
+		stats.LevelFiles = levels
+	}
+	if levels, err := db.Stats(); err == nil {
+		stats.Levels = levels
+	}
+
+	return json.Marshal(&stats)
+}
+
+// Size returns the approximate file system space used by all the keys of
+// the database. Like GetApproximateSizes, it may not include recently
+// written data.
+func (db *DB) Size() uint64 {
+	return db.SizeOf(nil)
+}
+
+// SizeOf returns the approximate file system space used by the keys
+// starting with prefix. Like ApproximateSize, it may not include
+// recently written data.
+//
+// Like the other prefix helpers, it expects the keys with the prefix to be
+// adjacent in the order of the DB, as they are in the default bytewise
+// order. With a custom comparator, the range measured goes from the first
+// to the last key with the prefix, which is left out.
+func (db *DB) SizeOf(prefix []byte) uint64 {
+	if !db.bytewise {
+		first, last, ok := db.prefixBounds(prefix)
+		if !ok {
+			return 0
+		}
+		return db.ApproximateSize(Range{first, last})
+	}
+	limit := prefixSuccessor(prefix)
+	if limit == nil {
+		// All the keys after prefix start with it: end past the last key.
+		ro := NewReadOptions()
+		defer ro.Destroy()
+		ro.SetFillCache(false)
+		it := db.NewIterator(ro)
+		defer it.Close()
+
+		it.SeekToLast()
+		if !it.Valid() {
+			return 0
+		}
+		// Appending a zero byte gives the key right after the last one in
+		// bytewise order, and a key past it with most comparators.
+		limit = append(it.Key(), 0)
+	}
+	return db.ApproximateSize(Range{prefix, limit})
+}
+
+// LevelStats holds the figures of a level in the "leveldb.stats" property.
+// Time, Read and Write are the totals of the compactions that produced files
+// at the level.
+type LevelStats struct {
+	Level   int     `json:"level"`
+	Files   int     `json:"files"`
+	SizeMB  float64 `json:"size_mb"`
+	TimeSec float64 `json:"time_sec"`
+	ReadMB  float64 `json:"read_mb"`
+	WriteMB float64 `json:"write_mb"`
+}
+
+// Stats returns the per-level table of the "leveldb.stats" property, parsed.
+// LevelDB leaves out the levels that never had files, and so does Stats.
+func (db *DB) Stats() ([]LevelStats, error) {
+	value, err := db.StatsText()
+	if err != nil {
+		return nil, err
+	}
+	return parseStats(value)
+}
+
+// parseStats parses the value of the "leveldb.stats" property:
+//
+//	                               Compactions
+//	Level  Files Size(MB) Time(sec) Read(MB) Write(MB)
+//	--------------------------------------------------
+//	  0        1        0         0        0         0
+//
+// The lines that don't start with a level number are skipped.
+func parseStats(value string) ([]LevelStats, error) {
+	var levels []LevelStats
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		level, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // header or separator
+		}
+		if len(fields) != 6 {
+			return nil, errors.New("goleveldb: malformed leveldb.stats line " + strconv.Quote(line))
+		}
+
+		s := LevelStats{Level: level}
+		s.Files, err = strconv.Atoi(fields[1])
+		for i, f := range []*float64{&s.SizeMB, &s.TimeSec, &s.ReadMB, &s.WriteMB} {
+			if err != nil {
+				break
+			}
+			*f, err = strconv.ParseFloat(fields[2+i], 64)
+		}
+		if err != nil {
+			return nil, errors.New("goleveldb: malformed leveldb.stats line " + strconv.Quote(line))
+		}
+		if s.Files == 0 && s.SizeMB == 0 && s.TimeSec == 0 && s.ReadMB == 0 && s.WriteMB == 0 {
+			continue
+		}
+		levels = append(levels, s)
+	}
+	return levels, nil
+}
+
+// CountExact returns the number of keys in the database, counted by
+// iterating over all of them; see ApproximateKeyCount for large databases.
+//
+// Set the ReadOptions default if ro == nil, except that the blocks read
+// don't fill the cache.
+func (db *DB) CountExact(ro *ReadOptions) (uint64, error) {
+	n, err := db.CountRange(ro, Range{})
+	return uint64(n), err
+}
+
+// ApproximateCountSample is about how many keys ApproximateKeyCount counts
+// before extrapolating.
+var ApproximateCountSample = 10000
+
+// approximateCountSegments is the number of parts of the key space
+// ApproximateKeyCount samples.
+const approximateCountSegments = 8
+
+// ApproximateKeyCount estimates the number of keys in the database, reading
+// about ApproximateCountSample of them rather than all.
+//
+// The key space is split in segments, and the first keys of each segment
+// are counted; the count of a segment is then extrapolated from the ratio of
+// its approximate size to the size of the keys counted. The segments with
+// few enough keys, or whose keys are still in memory rather than in table
+// files, are counted exactly. The estimate is good when the entries of a
+// segment have about the same size; with a custom comparator the key space
+// is a single segment.
+//
+// Set the ReadOptions default if ro == nil, except that the blocks read
+// don't fill the cache.
+func (db *DB) ApproximateKeyCount(ro *ReadOptions) (uint64, error) {
+	if db.db == nil {
+		return 0, ErrClosed
+	}
+	if ro == nil {
+		ro = NewReadOptions()
+		defer ro.Destroy()
+		ro.SetFillCache(false)
+	}
+	segments := db.keySegments(approximateCountSegments)
+	sample := ApproximateCountSample / len(segments)
+	if sample < 1 {
+		sample = 1
+	}
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	it.SeekToLast()
+	if !it.Valid() {
+		return 0, it.GetError()
+	}
+	// Appending a zero byte gives the key right after the last one, to bound
+	// the size of the last segment.
+	end := append(it.Key(), 0)
+
+	var count float64
+	for _, r := range segments {
+		inSegment := func() bool {
+			return it.Valid() && (r.Limit == nil || db.cmp(it.Key(), r.Limit) < 0)
+		}
+		n := 0
+		for it.Seek(r.Start); n < sample && inSegment(); it.Next() {
+			n++
+		}
+		if inSegment() {
+			limit := r.Limit
+			if limit == nil {
+				limit = end
+			}
+			sizes := db.GetApproximateSizes([]Range{{r.Start, it.Key()}, {r.Start, limit}})
+			if sizes[0] > 0 {
+				count += float64(n) * float64(sizes[1]) / float64(sizes[0])
+				continue
+			}
+			// Nothing to compare with in the table files: count them all.
+			for ; inSegment(); it.Next() {
+				n++
+			}
+		}
+		count += float64(n)
+	}
+	if err := it.GetError(); err != nil {
+		return 0, err
+	}
+	return uint64(count + 0.5), nil
+}
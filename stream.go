@@ -0,0 +1,71 @@
+package goleveldb
+
+// EntryStream is a pull-based sequence over the entries of a DB, in key
+// order. It is a thin layer over an Iterator for consumers that want one
+// entry at a time, without dealing with positioning.
+//
+// A typical use looks like:
+//
+// 	s := db.Entries(readOpts)
+// 	defer s.Close()
+// 	for key, value, ok := s.Next(); ok; key, value, ok = s.Next() {
+// 		...
+// 	}
+// 	if err := s.Err(); err != nil {
+// 		...
+// 	}
+//
+// To prevent memory leaks, an EntryStream must have Close called on it when
+// it is no longer needed by the program.
+type EntryStream struct {
+	it      *Iterator
+	started bool
+	done    bool
+	err     error
+}
+
+// Entries returns an EntryStream over all the entries of the database, read
+// with the ReadOptions given.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) Entries(ro *ReadOptions) *EntryStream {
+	return &EntryStream{it: db.NewIterator(ro)}
+}
+
+// Next returns the next entry. ok is false once the stream is exhausted or
+// failed, check Err to tell apart.
+//
+// The key and value are copies and may be retained.
+func (s *EntryStream) Next() (key, value []byte, ok bool) {
+	if s.done {
+		return nil, nil, false
+	}
+
+	if s.started {
+		s.it.Next()
+	} else {
+		s.it.SeekToFirst()
+		s.started = true
+	}
+	if !s.it.Valid() {
+		s.err = s.it.Error()
+		s.done = true
+		return nil, nil, false
+	}
+	return s.it.Key(), s.it.Value(), true
+}
+
+// Err returns the error that ended the stream, if any.
+func (s *EntryStream) Err() error {
+	return s.err
+}
+
+// Close releases the underlying Iterator. Next returns no more entries
+// afterwards.
+func (s *EntryStream) Close() {
+	if s.it != nil {
+		s.it.Close()
+		s.it = nil
+	}
+	s.done = true
+}
@@ -0,0 +1,130 @@
+package goleveldb
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeKind tells how a key changed between two scans.
+type ChangeKind int
+
+const (
+	KeyAdded ChangeKind = iota
+	KeyUpdated
+	KeyDeleted
+)
+
+// ChangeEvent reports a change of a key. Value is the new value, nil for
+// KeyDeleted.
+type ChangeEvent struct {
+	Kind  ChangeKind
+	Key   []byte
+	Value []byte
+}
+
+// SubscribePrefix watches the keys starting with prefix and sends the
+// changes found to the returned channel, one slice of events per interval,
+// sorted by key. Intervals without changes send nothing.
+//
+// Changes are found by scanning the prefix every interval and comparing with
+// the previous scan, so the changes within an interval are coalesced: a key
+// updated several times gives one KeyUpdated event with the last value, a
+// key added then deleted gives none. The first scan is the baseline and
+// reports nothing. A scan that fails is skipped, the first one is retried
+// every interval until one gives the baseline.
+//
+// The returned func stops watching and closes the channel. It must be called
+// before the DB is closed.
+func (db *DB) SubscribePrefix(prefix []byte, interval time.Duration) (<-chan []ChangeEvent, func()) {
+	prefix = append([]byte(nil), prefix...)
+	events := make(chan []ChangeEvent)
+	stop, done := make(chan struct{}), make(chan struct{})
+
+	// A nil prev means the baseline scan is still to be done.
+	prev, _ := db.scanPrefix(prefix)
+	go func() {
+		defer close(done)
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			cur, err := db.scanPrefix(prefix)
+			if err != nil {
+				continue
+			}
+			if prev == nil {
+				prev = cur
+				continue
+			}
+			changes := db.diffScans(prev, cur)
+			prev = cur
+			if len(changes) == 0 {
+				continue
+			}
+			select {
+			case <-stop:
+				return
+			case events <- changes:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+		})
+	}
+}
+
+// scanPrefix returns the entries whose key starts with prefix, indexed by
+// string(key).
+func (db *DB) scanPrefix(prefix []byte) (map[string][]byte, error) {
+	it := db.NewIterator(nil)
+	defer it.Close()
+
+	entries := make(map[string][]byte)
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Key()
+		if !bytes.HasPrefix(key, prefix) {
+			break
+		}
+		entries[string(key)] = it.Value()
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// diffScans returns the changes from prev to cur, sorted by key.
+func (db *DB) diffScans(prev, cur map[string][]byte) []ChangeEvent {
+	var changes []ChangeEvent
+	for key, value := range cur {
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			changes = append(changes, ChangeEvent{KeyAdded, []byte(key), value})
+		case !bytes.Equal(old, value):
+			changes = append(changes, ChangeEvent{KeyUpdated, []byte(key), value})
+		}
+	}
+	for key := range prev {
+		if _, ok := cur[key]; !ok {
+			changes = append(changes, ChangeEvent{KeyDeleted, []byte(key), nil})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return db.cmp(changes[i].Key, changes[j].Key) < 0
+	})
+	return changes
+}
@@ -0,0 +1,227 @@
+package goleveldb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// ErrOrderViolation means that the keys stored in a database are not in the
+// order of its comparator. VerifyOrder returns it as an *OrderViolationError.
+var ErrOrderViolation = errors.New("goleveldb: keys out of order")
+
+// OrderViolationError reports two consecutive keys of a database that are
+// not in strictly increasing order.
+type OrderViolationError struct {
+	Prev []byte
+	Key  []byte
+}
+
+func (e *OrderViolationError) Error() string {
+	return fmt.Sprintf("%v: %q is followed by %q", ErrOrderViolation, e.Prev, e.Key)
+}
+
+// Is makes errors.Is(err, ErrOrderViolation) true.
+func (e *OrderViolationError) Is(target error) bool {
+	return target == ErrOrderViolation
+}
+
+// Verify reads the whole database, verifying the checksums of all the data
+// it goes through, and returns the first error found.
+func (db *DB) Verify() error {
+	return db.VerifyRange(Range{})
+}
+
+// VerifyRange reads the keys in [r.Start, r.Limit), verifying the checksums
+// of all the data it goes through, and returns the first error found. A nil
+// Start or Limit leaves the range unbounded on that side.
+//
+// Finding the end of the range reads the first key at or after r.Limit, so
+// corruption of the block holding it is reported too. The blocks read are
+// not added to the block cache.
+func (db *DB) VerifyRange(r Range) error {
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetVerifyChecksums(true)
+	ro.SetFillCache(false)
+
+	it := db.NewIterator(ro)
+	defer it.Close()
+	for it.Seek(r.Start); it.Valid(); it.Next() {
+		if r.Limit != nil && db.cmp(it.Key(), r.Limit) >= 0 {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// VerifyOrder scans the whole database and checks that each key is strictly
+// greater than the previous one according to the DB's comparator. It returns
+// an *OrderViolationError for the first pair of keys out of order.
+//
+// LevelDB refuses to open a database with a comparator of a different name
+// than the one it was created with, but can't tell when a comparator keeps
+// its name and changes its ordering. Such a database returns wrong results.
+//  NOTE: VerifyOrder can't detect a comparator reversed or otherwise
+//  reordered: LevelDB's iterators take a key the comparator doesn't put
+//  after the previous one for an older version of it, and skip it, so the
+//  keys out of order never reach VerifyOrder. It only detects a comparator
+//  that is not consistent with itself, e.g. one that never returns a
+//  negative value.
+//
+// Set the ReadOptions default if ro == nil
+func (db *DB) VerifyOrder(ro *ReadOptions) error {
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	var prev []byte
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key := it.Key()
+		if prev != nil && db.cmp(prev, key) >= 0 {
+			return &OrderViolationError{Prev: prev, Key: key}
+		}
+		prev = key
+	}
+	return it.Error()
+}
+
+// VerifyOptions tune the verification of OpenVerified.
+type VerifyOptions struct {
+	// Sample is the fraction of the key space to verify, between 0 and 1.
+	// The key space is split in segments, of which a random Sample share is
+	// verified. 0 and values above 1 verify everything.
+	Sample float64
+}
+
+// verifySegments is the number of segments OpenVerified splits the key
+// space of a database into.
+const verifySegments = 64
+
+// VerifyError lists the key ranges in which OpenVerified found errors,
+// along with the first error of each range.
+type VerifyError struct {
+	Ranges []Range
+	Errs   []error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("goleveldb: errors in %d key ranges, first in [%q, %q): %v",
+		len(e.Ranges), e.Ranges[0].Start, e.Ranges[0].Limit, e.Errs[0])
+}
+
+// Unwrap returns the first error found, to test it with errors.Is.
+func (e *VerifyError) Unwrap() error {
+	return e.Errs[0]
+}
+
+// OpenVerified opens the database like Open, with paranoid checks turned on
+// in opt, and verifies the checksums of its data before returning it, to
+// detect corruption on startup rather than on the first reads going through
+// the corrupted blocks.
+//
+// The key space is split in segments, verified separately so that all the
+// damaged ones are found: if any, the database is closed and a *VerifyError
+// listing them is returned. A truncated table file is reported as a
+// corruption or an IO error, depending on where it was cut. With a
+// custom comparator the key space can't be split, and the verification
+// stops at the first corrupted block.
+//
+// Verifying reads the whole database, unless vo samples it. The blocks read
+// are not added to the block cache.
+//
+// Set the VerifyOptions default (verify everything) if vo == nil
+func OpenVerified(dbname string, opt *Options, vo *VerifyOptions) (*DB, error) {
+	if opt == nil {
+		opt = NewOptions()
+		defer opt.Destroy()
+	}
+	opt.SetParanoidChecks(true)
+	db, err := Open(dbname, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := db.keySegments(verifySegments)
+	if vo != nil && vo.Sample > 0 && vo.Sample < 1 {
+		n := int(float64(len(segments))*vo.Sample + 0.5)
+		if n == 0 {
+			n = 1
+		}
+		rand.Shuffle(len(segments), func(i, j int) {
+			segments[i], segments[j] = segments[j], segments[i]
+		})
+		segments = segments[:n]
+	}
+
+	verr := &VerifyError{}
+	for _, r := range segments {
+		if err := db.VerifyRange(r); err != nil {
+			verr.Ranges = append(verr.Ranges, r)
+			verr.Errs = append(verr.Errs, err)
+		}
+	}
+	if verr.Ranges != nil {
+		db.Close()
+		return nil, verr
+	}
+	return db, nil
+}
+
+// keySegments splits the key space of the database in up to n consecutive
+// ranges of about the same width in bytewise order, interpolating between
+// its first and last keys. The first range has no Start and the last one no
+// Limit, so that the ranges cover all the keys. With a custom comparator,
+// the key space is a single range.
+func (db *DB) keySegments(n int) []Range {
+	if !db.bytewise {
+		return []Range{{}}
+	}
+	ro := NewReadOptions()
+	defer ro.Destroy()
+	ro.SetFillCache(false)
+	it := db.NewIterator(ro)
+	defer it.Close()
+
+	// Reading the first and last keys can fail on a corrupted block: the
+	// segments are then as good as the keys found.
+	it.SeekToFirst()
+	if !it.Valid() {
+		return []Range{{}}
+	}
+	first := it.Key()
+	it.SeekToLast()
+	if !it.Valid() {
+		return []Range{{}}
+	}
+	last := it.Key()
+
+	// The keys between first and last share their common prefix, the 8
+	// bytes after it are interpolated.
+	common := 0
+	for common < len(first) && common < len(last) && first[common] == last[common] {
+		common++
+	}
+	word := func(key []byte) uint64 {
+		var b [8]byte
+		copy(b[:], key[common:])
+		return binary.BigEndian.Uint64(b[:])
+	}
+	lo, hi := word(first), word(last)
+
+	segments := []Range{{}}
+	var prev uint64
+	for i := 1; i < n; i++ {
+		w := lo + uint64(float64(hi-lo)*float64(i)/float64(n))
+		if w == prev || w <= lo {
+			continue
+		}
+		prev = w
+		bound := make([]byte, common+8)
+		copy(bound, first[:common])
+		binary.BigEndian.PutUint64(bound[common:], w)
+		segments[len(segments)-1].Limit = bound
+		segments = append(segments, Range{Start: bound})
+	}
+	return segments
+}
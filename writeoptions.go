@@ -4,23 +4,40 @@ package goleveldb
 // #include "leveldb/c.h"
 import "C"
 
+import (
+	"runtime"
+)
+
 // Options that control write operations
 //
 // To prevent memory leaks, Destroy must called on a WriteOptions when the
 // program no longer needs it.
 type WriteOptions struct {
 	opt *C.leveldb_writeoptions_t
+
+	// The C API has no getters, so the settings are mirrored here.
+	sync bool
+
+	pooled int32 // 1 while in the pool of GetWriteOptions
 }
 
 // NewWriteOptions allocates a new WriteOptions object.
 func NewWriteOptions() *WriteOptions {
-	return &WriteOptions{C.leveldb_writeoptions_create()}
+	wo := &WriteOptions{opt: C.leveldb_writeoptions_create()}
+	runtime.SetFinalizer(wo, finalize)
+	trackAlloc(wo, "WriteOptions")
+	return wo
 }
 
 // Destroy deallocates the WriteOptions, freeing its underlying C struct.
 func (wo *WriteOptions) Destroy() {
+	if wo.opt == nil {
+		return
+	}
+	runtime.SetFinalizer(wo, nil)
 	C.leveldb_writeoptions_destroy(wo.opt)
 	wo.opt = nil
+	trackFree(wo)
 }
 
 // If true, the write will be flushed from the operating system
@@ -41,4 +58,19 @@ func (wo *WriteOptions) Destroy() {
 //  Default: false
 func (wo *WriteOptions) SetSync(b bool) {
 	C.leveldb_writeoptions_set_sync(wo.opt, bool2uchar(b))
+	wo.sync = b
+}
+
+// Sync returns the setting of SetSync.
+func (wo *WriteOptions) Sync() bool {
+	return wo.sync
+}
+
+// Clone returns a new WriteOptions with the same settings as wo, to derive
+// options from a base without modifying it. The clone must be destroyed
+// separately.
+func (wo *WriteOptions) Clone() *WriteOptions {
+	c := NewWriteOptions()
+	c.SetSync(wo.sync)
+	return c
 }